@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ibihim/kube-plays/pkg/audit"
+	"github.com/ibihim/kube-plays/pkg/cmdutil"
+	"github.com/ibihim/kube-plays/pkg/logs"
+	"github.com/ibihim/kube-plays/pkg/namespaceapply"
+	"github.com/ibihim/kube-plays/pkg/sccgen"
+)
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if err := newRootCommand().ExecuteContext(ctx); err != nil {
+		os.Exit(1)
+	}
+}
+
+func newRootCommand() *cobra.Command {
+	flags := &cmdutil.Flags{}
+
+	cmd := &cobra.Command{
+		Use:          "kube-plays",
+		Short:        "Tools to play with PodSecurity admission, SCCs and server-side apply",
+		SilenceUsage: true,
+	}
+	flags.AddFlags(cmd.PersistentFlags())
+
+	cmd.AddCommand(
+		audit.NewCommand(flags),
+		logs.NewCommand(flags),
+		namespaceapply.NewCommand(flags),
+		sccgen.NewCommand(flags),
+	)
+
+	return cmd
+}
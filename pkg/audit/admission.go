@@ -0,0 +1,295 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+
+	"github.com/ibihim/kube-plays/pkg/cmdutil"
+)
+
+// The config map holding the kube-apiserver config on OpenShift, which
+// includes the PodSecurity admission configuration.
+const (
+	apiserverConfigNamespace = "openshift-kube-apiserver"
+	apiserverConfigName      = "config"
+	apiserverConfigKey       = "config.yaml"
+)
+
+// apiserverConfig is the part of the kube-apiserver config that holds the
+// PodSecurity admission configuration.
+type apiserverConfig struct {
+	Admission struct {
+		PluginConfig map[string]struct {
+			Configuration podSecurityConfiguration `json:"configuration"`
+		} `json:"pluginConfig"`
+	} `json:"admission"`
+}
+
+// podSecurityConfiguration is a PodSecurityConfiguration of the
+// pod-security.admission.config.k8s.io API.
+type podSecurityConfiguration struct {
+	Defaults struct {
+		Enforce string `json:"enforce"`
+		Warn    string `json:"warn"`
+		Audit   string `json:"audit"`
+	} `json:"defaults"`
+	Exemptions struct {
+		Usernames      []string `json:"usernames"`
+		RuntimeClasses []string `json:"runtimeClasses"`
+		Namespaces     []string `json:"namespaces"`
+	} `json:"exemptions"`
+}
+
+// admissionConfig is the PodSecurity admission configuration as far as it
+// could be determined. Empty levels are unknown.
+type admissionConfig struct {
+	// Source tells where the configuration was read from or that it was
+	// inferred by probing.
+	Source  string
+	Enforce string
+	Warn    string
+	Audit   string
+	// Exemptions is nil if they are unknown.
+	Exemptions *admissionExemptions
+}
+
+// admissionExemptions are the users, runtime classes and namespaces that
+// PodSecurity admission doesn't check.
+type admissionExemptions struct {
+	Usernames      []string
+	RuntimeClasses []string
+	Namespaces     []string
+}
+
+// newAdmissionConfigCommand returns a command that reports the cluster
+// default levels and exemptions PodSecurity admission is configured with.
+func newAdmissionConfigCommand(flags *cmdutil.Flags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "admission-config",
+		Short: "Report the default levels and exemptions of PodSecurity admission, read from the config or probed",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := flags.Clientset()
+			if err != nil {
+				return err
+			}
+
+			config, err := readAdmissionConfig(cmd.Context(), client)
+			if err != nil {
+				log := flags.Logger()
+				if isNotReadable(err) {
+					log.Debug("Admission configuration not readable, probing it instead", "err", err)
+				} else {
+					log.Warn("Failed to read the admission configuration, probing it instead", "err", err)
+				}
+
+				config, err = probeAdmissionConfig(cmd.Context(), flags.Logger(), client)
+				if err != nil {
+					return fmt.Errorf("failed to read the admission configuration or probe it: %w", err)
+				}
+			}
+
+			return printAdmissionConfig(os.Stdout, config)
+		},
+	}
+}
+
+// readAdmissionConfig reads the PodSecurity admission configuration from the
+// kube-apiserver config, which is only accessible on OpenShift and with the
+// permission to read config maps in its namespace.
+func readAdmissionConfig(ctx context.Context, client kubernetes.Interface) (*admissionConfig, error) {
+	configMap, err := client.CoreV1().ConfigMaps(apiserverConfigNamespace).Get(ctx, apiserverConfigName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := configMap.Data[apiserverConfigKey]
+	if !ok {
+		return nil, fmt.Errorf("config map %s/%s has no %s", apiserverConfigNamespace, apiserverConfigName, apiserverConfigKey)
+	}
+
+	var config apiserverConfig
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s of config map %s/%s: %w", apiserverConfigKey, apiserverConfigNamespace, apiserverConfigName, err)
+	}
+
+	plugin, ok := config.Admission.PluginConfig["PodSecurity"]
+	if !ok {
+		return nil, errors.New("the kube-apiserver config has no PodSecurity plugin config")
+	}
+
+	podSecurity := plugin.Configuration
+	result := &admissionConfig{
+		Source:  fmt.Sprintf("config map %s/%s", apiserverConfigNamespace, apiserverConfigName),
+		Enforce: levelOrDefault(podSecurity.Defaults.Enforce),
+		Warn:    levelOrDefault(podSecurity.Defaults.Warn),
+		Audit:   levelOrDefault(podSecurity.Defaults.Audit),
+	}
+	result.Exemptions = &admissionExemptions{
+		Usernames:      podSecurity.Exemptions.Usernames,
+		RuntimeClasses: podSecurity.Exemptions.RuntimeClasses,
+		Namespaces:     podSecurity.Exemptions.Namespaces,
+	}
+
+	return result, nil
+}
+
+// levelOrDefault returns the level, or privileged, which PodSecurity admission
+// defaults unset levels to.
+func levelOrDefault(level string) string {
+	if level == "" {
+		return "privileged"
+	}
+
+	return level
+}
+
+// probeAdmissionConfig infers the default enforce and warn levels by
+// dry-run creating a privileged and a baseline pod in a scratch namespace
+// without PodSecurity labels. The audit level and the exemptions can't be
+// inferred this way and are left unknown.
+func probeAdmissionConfig(ctx context.Context, log *slog.Logger, client kubernetes.Interface) (*admissionConfig, error) {
+	namespace, cleanUp, err := createScratchNamespace(ctx, client, nil, "default")
+	if err != nil {
+		return nil, err
+	}
+	defer cleanUp()
+
+	// Controllers like OpenShift's label syncer may label the namespace
+	// right away, which takes precedence over the defaults.
+	if current, err := client.CoreV1().Namespaces().Get(ctx, namespace.Name, metav1.GetOptions{}); err == nil {
+		namespace = current
+	}
+	if labels := podSecurityLabels(namespace); len(labels) > 0 {
+		log.Info("Scratch namespace got PodSecurity labels, the probe reflects them instead of the defaults", "labels", labels)
+	}
+
+	probe := func(name string, privileged bool) (rejected, warned bool, err error) {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace.Name},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:  "probe",
+					Image: "busybox",
+				}},
+			},
+		}
+		if privileged {
+			pod.Spec.Containers[0].SecurityContext = &corev1.SecurityContext{Privileged: boolPtr(true)}
+		}
+
+		psViolations, err := dryRunPod(ctx, client, pod)
+		if err != nil {
+			return false, false, err
+		}
+		for _, psv := range psViolations {
+			switch psv.Mechanism {
+			case mechanismEnforce:
+				rejected = true
+			case mechanismWarn:
+				warned = true
+			}
+		}
+
+		return rejected, warned, nil
+	}
+
+	// The privileged pod violates baseline, the baseline pod only
+	// violates restricted.
+	privilegedRejected, privilegedWarned, err := probe("probe-privileged", true)
+	if err != nil {
+		return nil, err
+	}
+	baselineRejected, baselineWarned, err := probe("probe-baseline", false)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &admissionConfig{Source: "probe of scratch namespace " + namespace.Name}
+	switch {
+	case baselineRejected:
+		config.Enforce = "restricted"
+	case privilegedRejected:
+		config.Enforce = "baseline"
+	default:
+		config.Enforce = "privileged"
+	}
+	// Rejected pods don't get warnings, so the warn level is only known as
+	// far as the pods were admitted.
+	switch {
+	case baselineWarned:
+		config.Warn = "restricted"
+	case privilegedWarned:
+		config.Warn = "baseline"
+	case !privilegedRejected:
+		config.Warn = "privileged"
+	}
+
+	return config, nil
+}
+
+// podSecurityLabels returns the PodSecurity labels of the namespace.
+func podSecurityLabels(namespace *corev1.Namespace) map[string]string {
+	labels := map[string]string{}
+	for key, value := range namespace.Labels {
+		if strings.HasPrefix(key, "pod-security.kubernetes.io/") {
+			labels[key] = value
+		}
+	}
+
+	return labels
+}
+
+// printAdmissionConfig prints the configuration, marking what is unknown.
+func printAdmissionConfig(w io.Writer, config *admissionConfig) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Source:\t%s\n", config.Source)
+	fmt.Fprintf(tw, "Default enforce:\t%s\n", valueOrUnknown(config.Enforce))
+	fmt.Fprintf(tw, "Default warn:\t%s\n", valueOrUnknown(config.Warn))
+	fmt.Fprintf(tw, "Default audit:\t%s\n", valueOrUnknown(config.Audit))
+
+	if config.Exemptions == nil {
+		fmt.Fprintf(tw, "Exemptions:\t%s\n", valueOrUnknown(""))
+		return tw.Flush()
+	}
+	fmt.Fprintf(tw, "Exempt namespaces:\t%s\n", listOrNone(config.Exemptions.Namespaces))
+	fmt.Fprintf(tw, "Exempt usernames:\t%s\n", listOrNone(config.Exemptions.Usernames))
+	fmt.Fprintf(tw, "Exempt runtime classes:\t%s\n", listOrNone(config.Exemptions.RuntimeClasses))
+
+	return tw.Flush()
+}
+
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+
+	return value
+}
+
+func listOrNone(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+
+	return strings.Join(values, ", ")
+}
+
+// isNotReadable reports whether err means that the configuration can't be
+// read with the current permissions or on this kind of cluster.
+func isNotReadable(err error) bool {
+	return apierrors.IsNotFound(err) || apierrors.IsForbidden(err)
+}
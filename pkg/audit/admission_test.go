@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestReadAdmissionConfig(t *testing.T) {
+	configMap := func(data string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: apiserverConfigNamespace, Name: apiserverConfigName},
+			Data:       map[string]string{apiserverConfigKey: data},
+		}
+	}
+
+	for _, tt := range []struct {
+		name      string
+		configMap *corev1.ConfigMap
+		want      *admissionConfig
+		notFound  bool
+	}{
+		{
+			name: "should read the defaults and exemptions",
+			configMap: configMap(`{"admission":{"pluginConfig":{"PodSecurity":{"configuration":{
+				"defaults":{"enforce":"restricted","warn":"restricted"},
+				"exemptions":{"usernames":["system:serviceaccount:openshift-infra:build-controller"]}}}}}}`),
+			want: &admissionConfig{
+				Source:  "config map openshift-kube-apiserver/config",
+				Enforce: "restricted",
+				Warn:    "restricted",
+				Audit:   "privileged",
+				Exemptions: &admissionExemptions{
+					Usernames: []string{"system:serviceaccount:openshift-infra:build-controller"},
+				},
+			},
+		},
+		{
+			name:     "should fail if the config map doesn't exist",
+			notFound: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := fake.NewSimpleClientset()
+			if tt.configMap != nil {
+				client = fake.NewSimpleClientset(tt.configMap)
+			}
+
+			got, err := readAdmissionConfig(context.Background(), client)
+			if tt.notFound {
+				if !isNotReadable(err) {
+					t.Fatalf("got error %v, want not found", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
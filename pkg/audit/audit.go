@@ -0,0 +1,767 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/ibihim/kube-plays/pkg/cmdutil"
+)
+
+type options struct {
+	output            string
+	patchesPath       string
+	sccPath           string
+	applyClean        bool
+	confirm           bool
+	includeWarn       bool
+	manifestPath      string
+	manifestNamespace string
+	watch             bool
+	metricsAddr       string
+	workers           int
+	contexts          []string
+	noColor           bool
+	levelsPath        string
+	targetLevel       string
+	qps               float32
+	burst             int
+	baselinePath      string
+	top               int
+	includeObjects    bool
+	onlyViolations    bool
+	ownerAnnotation   string
+	splitOutputDir    string
+	verifyRejection   bool
+	repeat            time.Duration
+	decisions         bool
+}
+
+// NewCommand returns the audit command, which reports the pods that would
+// violate PodSecurity if the namespaces enforced their audit level.
+func NewCommand(flags *cmdutil.Flags) *cobra.Command {
+	opts := &options{}
+	filter := &namespaceFilter{}
+
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Report pods that would violate PodSecurity if namespaces enforced their audit level",
+		Args:  cobra.NoArgs,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return filter.load()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Clean namespaces are only worth listing when they are about
+			// to be enforced.
+			if !cmd.Flags().Changed("only-violations") {
+				opts.onlyViolations = !opts.applyClean
+			}
+			if opts.repeat > 0 {
+				if opts.watch || opts.manifestPath != "" || opts.applyClean || opts.baselinePath != "" || opts.metricsAddr != "" {
+					return errors.New("--repeat can't be combined with --watch, --manifest, --apply-clean, --baseline or --metrics-addr")
+				}
+
+				return repeatRun(cmd.Context(), flags.Logger(), opts.repeat, func(ctx context.Context) error {
+					return run(ctx, flags, opts, filter)
+				})
+			}
+
+			return run(cmd.Context(), flags, opts, filter)
+		},
+	}
+
+	filter.addFlags(cmd.PersistentFlags())
+	cmd.Flags().StringVarP(&opts.output, "output", "o", outputJSON, "output format of the report, one of json, jsonl, yaml, table, html or markdown")
+	cmd.Flags().StringVar(&opts.splitOutputDir, "split-output-dir", "", "also write a report per namespace in the -o format into this directory, including namespaces without violations")
+	cmd.Flags().BoolVar(&opts.includeObjects, "include-objects", false, "include the full pod and workload objects in the json or yaml report, which makes it many times larger")
+	cmd.Flags().BoolVar(&opts.onlyViolations, "only-violations", true, "leave namespaces without violations out of the report, they are still counted in the summary (defaults to false with --apply-clean)")
+	cmd.Flags().StringVar(&opts.ownerAnnotation, "owner-annotation", "", "namespace annotation naming the team that owns it, e.g. team; adds the owner to each namespace and rolls the violations up per owner")
+	cmd.Flags().BoolVar(&opts.noColor, "no-color", false, "don't color the table output, even on a terminal")
+	cmd.Flags().StringVar(&opts.patchesPath, "patches-file", "", "write JSON patches that remediate the violating workloads to this file")
+	cmd.Flags().StringVar(&opts.sccPath, "scc-file", "", "write a candidate OpenShift SCC for every violating workload to this file")
+	cmd.Flags().BoolVar(&opts.applyClean, "apply-clean", false, "enforce the audit level on namespaces without violations (requires --confirm)")
+	cmd.Flags().BoolVar(&opts.confirm, "confirm", false, "confirm that --apply-clean may update namespaces for real")
+	cmd.Flags().BoolVar(&opts.includeWarn, "include-warn", false, "also collect the violations of each namespace's warn level")
+	cmd.Flags().BoolVar(&opts.verifyRejection, "verify-rejection", false, "dry-run create the spec of each violating pod in a scratch namespace enforcing the level, to tell pods new copies of which would be rejected from those only warned about")
+	cmd.Flags().StringVar(&opts.manifestPath, "manifest", "", "dry-run create the pod of this workload manifest instead of auditing namespaces")
+	cmd.Flags().BoolVar(&opts.decisions, "decisions", false, "with --manifest, print whether enforcing the --target-level rejects, restricted only warns about or admits the pod for each control")
+	cmd.Flags().StringVar(&opts.manifestNamespace, "namespace", "default", "namespace whose target level the --manifest pod is checked against")
+	cmd.Flags().StringVar(&opts.metricsAddr, "metrics-addr", "", "serve Prometheus metrics of the found violations on this address, e.g. :9090")
+	cmd.Flags().IntVar(&opts.workers, "workers", 4, "number of namespaces to audit in parallel")
+	cmd.Flags().StringVar(&opts.levelsPath, "levels-file", "", "YAML file with the level to enforce per namespace name or label selector, overriding --target-level")
+	cmd.Flags().StringVar(&opts.targetLevel, "target-level", "", "level to enforce on namespaces the --levels-file doesn't cover, defaults to each namespace's audit level")
+	cmd.Flags().StringVar(&opts.baselinePath, "baseline", "", "compare with this json report of a previous run, print new, fixed and persisting violations on stderr and fail on new ones")
+	cmd.Flags().IntVar(&opts.top, "top", 0, "instead of the report, print this many of the most violated controls with their share of all violations and the number of workloads affected")
+	cmd.Flags().Float32Var(&opts.qps, "qps", 20, "maximum number of API requests per second the audit makes")
+	cmd.Flags().IntVar(&opts.burst, "burst", 40, "maximum burst of API requests above --qps")
+	cmd.Flags().StringSliceVar(&opts.contexts, "contexts", nil, "audit each of these kubeconfig contexts and combine the results into one report")
+	cmd.Flags().BoolVar(&opts.watch, "watch", false, "keep running and print violations as they appear or get resolved")
+	cmd.Flags().DurationVar(&opts.repeat, "repeat", 0, "re-run the whole audit on this interval and print a fresh report each time, until interrupted")
+
+	cmd.AddCommand(
+		newLabelsCommand(flags, filter),
+		newGapCommand(flags, filter),
+		newSyncerCommand(flags, filter),
+		newAdmissionConfigCommand(flags),
+	)
+
+	return cmd
+}
+
+func run(ctx context.Context, flags *cmdutil.Flags, opts *options, filter *namespaceFilter) error {
+	log := flags.Logger()
+
+	if err := validateOutput(opts.output); err != nil {
+		return err
+	}
+
+	if opts.applyClean && !opts.confirm {
+		return errors.New("--apply-clean updates namespaces for real, pass --confirm to proceed")
+	}
+
+	if opts.watch && (opts.manifestPath != "" || opts.applyClean || opts.patchesPath != "" || opts.sccPath != "") {
+		return errors.New("--watch can't be combined with --manifest, --apply-clean, --patches-file or --scc-file")
+	}
+
+	if len(opts.contexts) > 0 && (opts.watch || opts.manifestPath != "") {
+		return errors.New("--contexts can't be combined with --watch or --manifest")
+	}
+
+	if opts.baselinePath != "" && (opts.watch || opts.manifestPath != "") {
+		return errors.New("--baseline can't be combined with --watch or --manifest")
+	}
+
+	if opts.includeObjects && opts.output != outputJSON && opts.output != outputYAML {
+		return errors.New("--include-objects requires -o json or -o yaml")
+	}
+
+	if opts.top > 0 && (opts.watch || opts.manifestPath != "" || opts.output == outputJSONL) {
+		return errors.New("--top can't be combined with --watch, --manifest or -o jsonl")
+	}
+
+	if opts.splitOutputDir != "" && (opts.watch || opts.manifestPath != "" || opts.top > 0 || opts.output == outputJSONL) {
+		return errors.New("--split-output-dir can't be combined with --watch, --manifest, --top or -o jsonl")
+	}
+
+	if opts.verifyRejection && (opts.watch || opts.manifestPath != "" || opts.output == outputJSONL) {
+		return errors.New("--verify-rejection can't be combined with --watch, --manifest or -o jsonl")
+	}
+
+	if opts.decisions && opts.manifestPath == "" {
+		return errors.New("--decisions requires --manifest")
+	}
+
+	targets, err := newLevelTargets(opts.levelsPath, opts.targetLevel)
+	if err != nil {
+		return err
+	}
+
+	var baseline *Report
+	if opts.baselinePath != "" {
+		baseline, err = loadBaseline(opts.baselinePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	var m *metrics
+	if opts.metricsAddr != "" {
+		reg := prometheus.NewRegistry()
+		m = newMetrics(reg)
+		serveMetrics(ctx, log, opts.metricsAddr, reg)
+	}
+
+	if opts.watch || opts.manifestPath != "" {
+		client, err := rateLimitedClientset(flags, opts.qps, opts.burst)
+		if err != nil {
+			return err
+		}
+
+		if opts.watch {
+			return watchNamespaces(ctx, log, client, filter, opts.includeWarn, targets, m, os.Stdout)
+		}
+		if opts.decisions {
+			level := opts.targetLevel
+			if level == "" {
+				level = defaultTargetLevel
+			}
+			return probeManifestDecisions(ctx, client, opts.manifestPath, level, opts.output)
+		}
+
+		return auditManifest(ctx, log, client, opts, targets, m)
+	}
+
+	// Audit the current context, or each of --contexts.
+	clusters := opts.contexts
+	if len(clusters) == 0 {
+		clusters = []string{""}
+	}
+
+	type cleanCluster struct {
+		cluster    string
+		client     kubernetes.Interface
+		namespaces []*corev1.Namespace
+		// enforcing are the namespaces that enforce their level already.
+		enforcing []string
+	}
+
+	var (
+		psViolations  []*PSViolation
+		cleanClusters []cleanCluster
+		notFound      []string
+
+		stream         *json.Encoder
+		keepViolations = opts.patchesPath != "" || opts.sccPath != "" || baseline != nil
+	)
+	if opts.output == outputJSONL {
+		stream = json.NewEncoder(os.Stdout)
+	}
+	for _, cluster := range clusters {
+		clusterFlags := flags
+		if cluster != "" {
+			clusterFlags = flags.ForContext(cluster)
+		}
+
+		client, err := rateLimitedClientset(clusterFlags, opts.qps, opts.burst)
+		if err != nil {
+			return fmt.Errorf("error creating client for context %q: %w", cluster, err)
+		}
+
+		// Gather all the violations for each namespace, page by page. With
+		// jsonl they are written as they come in and only kept if later
+		// steps need them.
+		clean := cleanCluster{cluster: cluster, client: client}
+		clusterViolations := len(psViolations)
+		err = auditNamespaces(ctx, client, filter, opts.includeWarn, targets, opts.workers, newProgress(os.Stderr, flags.Quiet), func(result *namespaceResult) error {
+			for _, psv := range result.violations {
+				psv.Cluster = cluster
+				if opts.ownerAnnotation != "" {
+					psv.Owner = namespaceOwner(result.stricter, opts.ownerAnnotation)
+				}
+				m.observe(psv)
+				if stream != nil {
+					if err := writeWorkloadLines(stream, newNamespaceReport(psv)); err != nil {
+						return err
+					}
+				}
+			}
+			if stream == nil || keepViolations {
+				psViolations = append(psViolations, result.violations...)
+			}
+			switch {
+			case result.alreadyEnforcing:
+				clean.enforcing = append(clean.enforcing, result.stricter.Name)
+			case result.clean():
+				clean.namespaces = append(clean.namespaces, result.stricter)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		cleanClusters = append(cleanClusters, clean)
+
+		if opts.verifyRejection {
+			if err := verifyRejections(ctx, log, client, psViolations[clusterViolations:]); err != nil {
+				return err
+			}
+		}
+
+		filter.logNotFound(log)
+		for _, name := range filter.notFound {
+			if cluster != "" {
+				name = cluster + "/" + name
+			}
+			notFound = append(notFound, name)
+		}
+	}
+
+	report := newReport(psViolations)
+	report.NotFound = notFound
+	for _, clean := range cleanClusters {
+		report.addClean(clean.cluster, clean.namespaces, !opts.onlyViolations)
+		report.addAlreadyEnforcing(clean.cluster, clean.enforcing)
+	}
+	if opts.includeObjects {
+		report.includeObjects(psViolations)
+	}
+	if opts.splitOutputDir != "" {
+		var cleanNamespaces, enforcingNamespaces []scannedNamespace
+		for _, clean := range cleanClusters {
+			for _, namespace := range clean.namespaces {
+				cleanNamespaces = append(cleanNamespaces, scannedNamespace{cluster: clean.cluster, namespace: namespace.Name})
+			}
+			for _, name := range clean.enforcing {
+				enforcingNamespaces = append(enforcingNamespaces, scannedNamespace{cluster: clean.cluster, namespace: name})
+			}
+		}
+		if err := writeSplitReports(opts.splitOutputDir, splitReport(report, cleanNamespaces, enforcingNamespaces), opts.output); err != nil {
+			return fmt.Errorf("error writing per-namespace reports: %w", err)
+		}
+	}
+	switch {
+	case opts.top > 0:
+		if err := writeTopControls(os.Stdout, topControls(report, opts.top), opts.output); err != nil {
+			return err
+		}
+	case stream == nil:
+		if err := writeReport(os.Stdout, report, opts.output, useColor(os.Stdout, opts.noColor)); err != nil {
+			return err
+		}
+	}
+
+	if opts.applyClean {
+		var errs []error
+		for _, clean := range cleanClusters {
+			errs = append(errs, enforceCleanNamespaces(ctx, log, clean.client, clean.namespaces))
+		}
+		if err := errors.Join(errs...); err != nil {
+			return err
+		}
+	}
+
+	if opts.patchesPath != "" {
+		if err := writeRemediationPatches(log, opts.patchesPath, psViolations); err != nil {
+			return err
+		}
+	}
+
+	if opts.sccPath != "" {
+		if err := writeSCCRecommendations(opts.sccPath, psViolations); err != nil {
+			return err
+		}
+	}
+
+	var added int
+	if baseline != nil {
+		added = compareBaseline(os.Stderr, baseline, report)
+	}
+
+	if opts.metricsAddr != "" {
+		waitForScrapes(ctx, log)
+	}
+
+	// Fail last, so that new violations don't skip anything.
+	if added > 0 {
+		return fmt.Errorf("found %d new violations compared to the baseline", added)
+	}
+
+	return nil
+}
+
+// rateLimitedClientset creates a client that makes at most qps requests per
+// second with bursts of up to burst requests, so that large audits stay
+// within the apiserver's budget.
+func rateLimitedClientset(flags *cmdutil.Flags, qps float32, burst int) (*kubernetes.Clientset, error) {
+	if qps <= 0 || burst < 1 {
+		return nil, errors.New("--qps and --burst must be positive")
+	}
+
+	config, err := flags.RESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.RateLimiter = flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+
+	return kubernetes.NewForConfig(config)
+}
+
+// auditManifest reports the violations of the --manifest workload.
+func auditManifest(ctx context.Context, log *slog.Logger, client kubernetes.Interface, opts *options, targets *levelTargets, m *metrics) error {
+	psViolations, err := checkManifest(ctx, client, opts.manifestNamespace, targets, opts.manifestPath)
+	if err != nil {
+		return err
+	}
+
+	for _, psv := range psViolations {
+		m.observe(psv)
+	}
+	report := newReport(psViolations)
+	if opts.includeObjects {
+		report.includeObjects(psViolations)
+	}
+	if err := writeReport(os.Stdout, report, opts.output, useColor(os.Stdout, opts.noColor)); err != nil {
+		return err
+	}
+
+	if opts.metricsAddr != "" {
+		waitForScrapes(ctx, log)
+	}
+
+	return nil
+}
+
+// resolveWorkload gets the violating pod, its top-most owner and, if that is
+// one, the Deployment it belongs to. Pods that aren't owned by a Deployment,
+// directly or through a ReplicaSet, or whose owners are gone, are left
+// without a Deployment, but their top-most owner is still named.
+func resolveWorkload(ctx context.Context, client kubernetes.Interface, namespace string, podViolation *PodViolation) error {
+	pod, err := client.CoreV1().Pods(namespace).Get(ctx, podViolation.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	podViolation.Pod = pod
+
+	kind, name, owner, err := resolveTopOwner(ctx, client, namespace, pod)
+	if err != nil {
+		return err
+	}
+	podViolation.HelmRelease, podViolation.HelmNamespace = helmRelease(pod)
+	podViolation.WorkloadKind, podViolation.WorkloadName = kind, name
+
+	// An owner that is gone or of an unknown kind is named, but has no
+	// object, and a bare pod's object is the pod itself.
+	switch owner := owner.(type) {
+	case nil, *corev1.Pod:
+		return nil
+	case *appsv1.Deployment:
+		podViolation.Deployment = owner
+	}
+	podViolation.WorkloadObject = owner
+	if workload, err := meta.Accessor(owner); err == nil {
+		if release, releaseNamespace := helmRelease(workload); release != "" {
+			podViolation.HelmRelease, podViolation.HelmNamespace = release, releaseNamespace
+		}
+	}
+
+	return nil
+}
+
+// Mechanisms of PodSecurity admission that violations are collected for.
+const (
+	mechanismEnforce = "enforce"
+	mechanismWarn    = "warn"
+)
+
+// Warnings Mapping
+type warningsMapper struct {
+	defaultHandler rest.WarningHandler
+	PSViolations   []*PSViolation
+}
+
+type PSViolation struct {
+	// Cluster is the kubeconfig context the violation was found in, if the
+	// audit covered several.
+	Cluster       string
+	Namespace     string
+	Level         string
+	Mechanism     string
+	LabelChanges  []LabelChange
+	PodViolations []*PodViolation
+	// AuditDefaulted is set if the namespace has no audit label and the level
+	// defaulted to restricted.
+	AuditDefaulted bool
+	// Owner is the value of the namespace's --owner-annotation.
+	Owner string
+}
+
+// LabelChange describes how a namespace label changes when the stricter
+// enforce level is applied.
+type LabelChange struct {
+	Key       string `json:"key"`
+	Operation string `json:"operation"`
+	From      string `json:"from,omitempty"`
+	To        string `json:"to,omitempty"`
+}
+
+// String returns the change in the form "enforce: (none) → restricted".
+func (c LabelChange) String() string {
+	from, to := c.From, c.To
+	if c.Operation == "added" {
+		from = "(none)"
+	}
+	if c.Operation == "removed" {
+		to = "(none)"
+	}
+
+	return fmt.Sprintf("%s: %s → %s", c.Key, from, to)
+}
+
+type PodViolation struct {
+	Name       string
+	Deployment *appsv1.Deployment
+	Pod        *corev1.Pod
+	Violations []string
+
+	// WorkloadKind and WorkloadName name the top-most owner of the pod,
+	// e.g. the CronJob of a Job's pod, or the pod itself if it is bare.
+	// WorkloadObject is the owner, unless it is gone, of an unknown kind or
+	// the pod itself.
+	WorkloadKind   string
+	WorkloadName   string
+	WorkloadObject runtime.Object
+
+	// HelmRelease and HelmNamespace name the Helm release that manages the
+	// pod's workload, if any.
+	HelmRelease   string
+	HelmNamespace string
+
+	// Rejected tells whether a pod with the same spec would be rejected on
+	// create, if that was verified.
+	Rejected *bool
+}
+
+var titleRegex = regexp.MustCompile(`"([^"]+)"`)
+
+// HandleWarningHeader implements the WarningHandler interface. It stores the
+// warning in the handler and forwards to the default handler.
+func (w *warningsMapper) HandleWarningHeader(code int, agent string, text string) {
+	if text == "" {
+		return
+	}
+
+	if len(w.PSViolations) == 0 {
+		w.PSViolations = []*PSViolation{}
+	}
+
+	switch {
+	case strings.HasPrefix(text, "existing pods in namespace"):
+		// Namespace Warning Message
+		// The text should look like "existing pods in namespace "my-namespace" violate the new PodSecurity enforce level "mylevel:v1.2.3"
+		titleMatches := titleRegex.FindAllStringSubmatch(text, -1)
+		if len(titleMatches) < 2 {
+			break
+		}
+
+		psv := PSViolation{
+			Namespace: titleMatches[0][1],
+			Level:     titleMatches[1][1],
+		}
+
+		w.PSViolations = append(w.PSViolations, &psv)
+	case strings.HasPrefix(text, "would violate PodSecurity"):
+		// Pod Create Warning Message
+		// The text should look like: would violate PodSecurity "mylevel:v1.2.3": {policy warning A}, {policy warning B}, ...
+		// It names neither the namespace nor the pod, the caller fills those in.
+		titleMatches := titleRegex.FindStringSubmatch(text)
+		_, violationText, ok := strings.Cut(text, `": `)
+		if titleMatches == nil || !ok {
+			break
+		}
+
+		psv := PSViolation{
+			Level: titleMatches[1],
+			PodViolations: []*PodViolation{
+				{Violations: splitViolations(violationText)},
+			},
+		}
+
+		w.PSViolations = append(w.PSViolations, &psv)
+	default:
+		// Pod Warning Message, assume last PSViolation is the one we belong to.
+		// Warnings that don't follow a namespace warning or don't look like
+		// a pod warning are ignored.
+		if len(w.PSViolations) == 0 {
+			break
+		}
+		lastPSViolation := w.PSViolations[len(w.PSViolations)-1]
+		// The text should look like this: {pod name}: {policy warning A}, {policy warning B}, ...
+		podText, violationText, ok := strings.Cut(text, ": ")
+		if !ok {
+			break
+		}
+		podName := strings.TrimSpace(podText)
+		violations := splitViolations(violationText)
+		podViolation := PodViolation{
+			Name:       podName,
+			Violations: violations,
+		}
+		lastPSViolation.PodViolations = append(lastPSViolation.PodViolations, &podViolation)
+	}
+
+	if w.defaultHandler == nil {
+		return
+	}
+
+	w.defaultHandler.HandleWarningHeader(code, agent, text)
+}
+
+// splitViolations splits a comma separated list of policy warnings. Commas
+// within the parenthesized details of a warning, like in
+// `hostPath volumes (volumes "a", "b")`, don't split.
+func splitViolations(text string) []string {
+	var (
+		violations []string
+		depth      int
+		start      int
+	)
+
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 && strings.HasPrefix(text[i:], ", ") {
+				violations = append(violations, text[start:i])
+				start = i + 2
+			}
+		}
+	}
+
+	return append(violations, text[start:])
+}
+
+// String returns the warnings that are stored by the handler as a JSON
+// encoded Report.
+func (w *warningsMapper) String() string {
+	if len(w.PSViolations) == 0 {
+		return ""
+	}
+
+	// Example Warning
+	// [0] existing pods in namespace "p0t-sekurity" violate the new PodSecurity enforce level "restricted:latest"
+	// [1] p0t-sekurity: allowPrivilegeEscalation != false, unrestricted capabilities, runAsNonRoot != true, seccompProfile
+
+	var b bytes.Buffer
+	if err := json.NewEncoder(&b).Encode(newReport(w.PSViolations)); err != nil {
+		return ""
+	}
+
+	return b.String()
+}
+
+// enforceCleanNamespaces updates the namespaces that didn't produce any
+// violations during the dry-run to their stricter enforce level. Conflicts
+// with other writers are retried on the latest version of the namespace. All
+// namespaces are attempted, the outcome of each is logged at the end.
+func enforceCleanNamespaces(ctx context.Context, log *slog.Logger, client kubernetes.Interface, cleanNamespaces []*corev1.Namespace) error {
+	failed := map[string]error{}
+	for _, namespace := range cleanNamespaces {
+		if err := enforceLevel(ctx, client, namespace.Name, namespace.Labels[enforceLabel]); err != nil {
+			failed[namespace.Name] = err
+		}
+	}
+
+	for _, namespace := range cleanNamespaces {
+		level := namespace.Labels[enforceLabel]
+		if err, ok := failed[namespace.Name]; ok {
+			log.Error("Failed to enforce level on namespace", "namespace", namespace.Name, "level", level, "err", err)
+			continue
+		}
+		log.Info("Enforced level on namespace", "namespace", namespace.Name, "level", level)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to enforce level on %d of %d namespaces", len(failed), len(cleanNamespaces))
+	}
+
+	return nil
+}
+
+// enforceLevel sets the enforce label of the namespace to level. It starts
+// from the namespace as it is now on every attempt, so labels that others
+// changed since the dry-run are kept.
+func enforceLevel(ctx context.Context, client kubernetes.Interface, name, level string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		namespace, err := client.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if namespace.Labels == nil {
+			namespace.Labels = map[string]string{}
+		}
+		namespace.Labels[enforceLabel] = level
+
+		_, err = client.CoreV1().Namespaces().Update(ctx, namespace, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// unassignedOwner is the owner of namespaces without the --owner-annotation.
+const unassignedOwner = "unassigned"
+
+// namespaceOwner returns the value of the namespace's owner annotation, or
+// unassigned if it has none.
+func namespaceOwner(namespace *corev1.Namespace, annotation string) string {
+	if owner := namespace.Annotations[annotation]; owner != "" {
+		return owner
+	}
+
+	return unassignedOwner
+}
+
+// labelDiff returns the labels that are added, changed or removed when going
+// from the original to the mapped namespace, sorted by key.
+func labelDiff(original, mapped *corev1.Namespace) []LabelChange {
+	changes := []LabelChange{}
+
+	for k, v := range mapped.Labels {
+		old, ok := original.Labels[k]
+		switch {
+		case !ok:
+			changes = append(changes, LabelChange{Key: k, Operation: "added", To: v})
+		case old != v:
+			changes = append(changes, LabelChange{Key: k, Operation: "changed", From: old, To: v})
+		}
+	}
+
+	for k, v := range original.Labels {
+		if _, ok := mapped.Labels[k]; !ok {
+			changes = append(changes, LabelChange{Key: k, Operation: "removed", From: v})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+
+	return changes
+}
+
+// mapAuditToEnforce returns a copy of the namespace that enforces its target
+// level: the level targets decide on, else its audit level, else restricted.
+// It reports whether it defaulted to restricted.
+func mapAuditToEnforce(namespace *corev1.Namespace, targets *levelTargets) (*corev1.Namespace, bool) {
+	ns := namespace.DeepCopy()
+	if ns.Labels == nil {
+		ns.Labels = map[string]string{}
+	}
+
+	level := targets.level(ns)
+	if level == "" {
+		level = ns.Labels[auditLabel]
+	}
+	defaulted := level == ""
+	if defaulted {
+		level = defaultTargetLevel
+	}
+
+	ns.Labels[enforceLabel] = level
+
+	return ns, defaulted
+}
+
+// mapWarnToEnforce returns a copy of the namespace that enforces its warn
+// level, or nil if the namespace has no warn level.
+func mapWarnToEnforce(namespace *corev1.Namespace) *corev1.Namespace {
+	level := namespace.Labels[warnLabel]
+	if level == "" {
+		return nil
+	}
+
+	ns := namespace.DeepCopy()
+	ns.Labels[enforceLabel] = level
+
+	return ns
+}
@@ -0,0 +1,229 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestWarningsMapper(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		warnings []string
+		want     []*PSViolation
+	}{
+		{
+			name: "should collect a namespace without pods",
+			warnings: []string{
+				`existing pods in namespace "p0t-sekurity" violate the new PodSecurity enforce level "restricted:latest"`,
+			},
+			want: []*PSViolation{
+				{Namespace: "p0t-sekurity", Level: "restricted:latest"},
+			},
+		},
+		{
+			name: "should collect a single pod",
+			warnings: []string{
+				`existing pods in namespace "p0t-sekurity" violate the new PodSecurity enforce level "restricted:latest"`,
+				`p0t-sekurity: allowPrivilegeEscalation != false, unrestricted capabilities, runAsNonRoot != true, seccompProfile`,
+			},
+			want: []*PSViolation{
+				{
+					Namespace: "p0t-sekurity",
+					Level:     "restricted:latest",
+					PodViolations: []*PodViolation{
+						{
+							Name:       "p0t-sekurity",
+							Violations: []string{"allowPrivilegeEscalation != false", "unrestricted capabilities", "runAsNonRoot != true", "seccompProfile"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "should collect multiple pods per namespace",
+			warnings: []string{
+				`existing pods in namespace "test-namespace-1" violate the new PodSecurity enforce level "restricted:latest"`,
+				`test-pod: allowPrivilegeEscalation != false`,
+				`privileged-deployment-7d4b9c6f5-x2x9z: privileged`,
+				`existing pods in namespace "test-namespace-3" violate the new PodSecurity enforce level "baseline:latest"`,
+				`test-pod: hostPath volumes`,
+			},
+			want: []*PSViolation{
+				{
+					Namespace: "test-namespace-1",
+					Level:     "restricted:latest",
+					PodViolations: []*PodViolation{
+						{Name: "test-pod", Violations: []string{"allowPrivilegeEscalation != false"}},
+						{Name: "privileged-deployment-7d4b9c6f5-x2x9z", Violations: []string{"privileged"}},
+					},
+				},
+				{
+					Namespace: "test-namespace-3",
+					Level:     "baseline:latest",
+					PodViolations: []*PodViolation{
+						{Name: "test-pod", Violations: []string{"hostPath volumes"}},
+					},
+				},
+			},
+		},
+		{
+			name: "should collect a pod create warning with details",
+			warnings: []string{
+				`would violate PodSecurity "restricted:latest": allowPrivilegeEscalation != false (container "busybox" must set securityContext.allowPrivilegeEscalation=false), hostPath volumes (volumes "a", "b")`,
+			},
+			want: []*PSViolation{
+				{
+					Level: "restricted:latest",
+					PodViolations: []*PodViolation{
+						{
+							Violations: []string{
+								`allowPrivilegeEscalation != false (container "busybox" must set securityContext.allowPrivilegeEscalation=false)`,
+								`hostPath volumes (volumes "a", "b")`,
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "should ignore a pod warning without a namespace warning",
+			warnings: []string{
+				`test-pod: allowPrivilegeEscalation != false`,
+			},
+			want: []*PSViolation{},
+		},
+		{
+			name: "should ignore malformed warnings",
+			warnings: []string{
+				`existing pods in namespace violate the new PodSecurity enforce level`,
+				`existing pods in namespace "test-namespace-1" violate the new PodSecurity enforce level "restricted:latest"`,
+				`new PodSecurity enforce level only checked against the first 3000 of 4000 existing pods`,
+				`would violate PodSecurity`,
+			},
+			want: []*PSViolation{
+				{Namespace: "test-namespace-1", Level: "restricted:latest"},
+			},
+		},
+		{
+			name:     "should ignore empty warnings",
+			warnings: []string{""},
+			want:     nil,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			wh := &warningsMapper{}
+			for _, warning := range tt.warnings {
+				wh.HandleWarningHeader(299, "", warning)
+			}
+
+			if !reflect.DeepEqual(wh.PSViolations, tt.want) {
+				t.Errorf("unexpected violations:\n%s\nwant:\n%s", dump(wh.PSViolations), dump(tt.want))
+			}
+		})
+	}
+}
+
+func dump(psViolations []*PSViolation) string {
+	wh := &warningsMapper{PSViolations: psViolations}
+	return wh.String()
+}
+
+func TestEnforceCleanNamespaces(t *testing.T) {
+	conflict := apierrors.NewConflict(corev1.Resource("namespaces"), "churny", errors.New("the object has been modified"))
+
+	for _, tt := range []struct {
+		name       string
+		conflicts  int
+		wantLabels map[string]string
+		wantErr    bool
+	}{
+		{
+			name:       "should retry on conflict and keep labels others added meanwhile",
+			conflicts:  2,
+			wantLabels: map[string]string{"team": "churn", enforceLabel: "restricted"},
+		},
+		{
+			name:      "should fail once the retries are used up",
+			conflicts: 100,
+			wantErr:   true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// The dry-run saw the namespace without the label another writer
+			// added since.
+			client := fake.NewSimpleClientset(&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "churny", Labels: map[string]string{"team": "churn"}},
+			})
+			conflicts := tt.conflicts
+			client.PrependReactor("update", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if conflicts == 0 {
+					return false, nil, nil
+				}
+				conflicts--
+				return true, nil, conflict
+			})
+			clean := []*corev1.Namespace{{
+				ObjectMeta: metav1.ObjectMeta{Name: "churny", Labels: map[string]string{enforceLabel: "restricted"}},
+			}}
+
+			log := slog.New(slog.NewTextHandler(io.Discard, nil))
+			err := enforceCleanNamespaces(context.Background(), log, client, clean)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			namespace, err := client.CoreV1().Namespaces().Get(context.Background(), "churny", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(namespace.Labels, tt.wantLabels) {
+				t.Errorf("got labels %v, want %v", namespace.Labels, tt.wantLabels)
+			}
+		})
+	}
+}
+
+func TestAuditNamespaceSkipsAlreadyEnforcedLevel(t *testing.T) {
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "strict",
+			Labels: map[string]string{auditLabel: "restricted", enforceLabel: "restricted"},
+		},
+	}
+	// The fake clientset has no REST client, the dry-run would panic.
+	client := fake.NewSimpleClientset(namespace)
+
+	result, err := auditNamespace(context.Background(), client, namespace, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.alreadyEnforcing {
+		t.Errorf("expected namespace %s to be already enforcing", namespace.Name)
+	}
+	if result.clean() {
+		t.Errorf("expected namespace %s not to be clean, there is nothing to apply", namespace.Name)
+	}
+	if actions := client.Actions(); len(actions) > 0 {
+		t.Errorf("expected no API calls, got %v", actions)
+	}
+}
@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// loadBaseline reads a report written by a previous run with --output json.
+func loadBaseline(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading baseline: %w", err)
+	}
+
+	report := &Report{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("error parsing baseline %s: %w", path, err)
+	}
+
+	return report, nil
+}
+
+// baselineKeys returns a key per namespace, workload and violated control of
+// the report, e.g. "p0t-sekurity Deployment/web: privileged". Pods of the
+// same workload share their keys, so that restarted pods with new names
+// don't show up as changes.
+func baselineKeys(report *Report) sets.Set[string] {
+	keys := sets.New[string]()
+	for _, nsReport := range report.Namespaces {
+		namespace := nsReport.Namespace
+		if nsReport.Cluster != "" {
+			namespace = nsReport.Cluster + "/" + namespace
+		}
+
+		for _, workload := range nsReport.Workloads() {
+			for _, violation := range workload.Violations() {
+				keys.Insert(fmt.Sprintf("%s %s/%s: %s", namespace, workload.Kind, workload.Name, ParseViolation(violation).Control))
+			}
+		}
+	}
+
+	return keys
+}
+
+// compareBaseline prints the violations of current that are new ("+"),
+// fixed ("-") and persisting ("=") compared to baseline, and returns the
+// number of new ones.
+func compareBaseline(out io.Writer, baseline, current *Report) int {
+	previous, now := baselineKeys(baseline), baselineKeys(current)
+	added := now.Difference(previous)
+	fixed := previous.Difference(now)
+	persisting := now.Intersection(previous)
+
+	for _, delta := range []struct {
+		prefix string
+		keys   sets.Set[string]
+	}{
+		{prefix: "+", keys: added},
+		{prefix: "-", keys: fixed},
+		{prefix: "=", keys: persisting},
+	} {
+		for _, key := range sets.List(delta.keys) {
+			fmt.Fprintf(out, "%s %s\n", delta.prefix, key)
+		}
+	}
+	fmt.Fprintf(out, "%d new, %d fixed, %d persisting violations compared to the baseline\n", added.Len(), fixed.Len(), persisting.Len())
+
+	return added.Len()
+}
@@ -0,0 +1,272 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/retry"
+)
+
+// apiBackoff is how often and how long the dry-run checks are retried when
+// the apiserver throttles them or times out.
+var apiBackoff = wait.Backoff{
+	Duration: 500 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    5,
+}
+
+// isRetriable reports whether err is the apiserver asking to come back
+// later, rather than a failed check.
+func isRetriable(err error) bool {
+	return apierrors.IsTooManyRequests(err) || apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err)
+}
+
+// CheckNamespace reports the pods that would violate PodSecurity if the
+// namespace enforced the level, along with the workloads they belong to. It
+// does a dry-run update, so the namespace is left untouched.
+func CheckNamespace(ctx context.Context, client kubernetes.Interface, nsName, level string) ([]*PodViolation, error) {
+	namespace, err := client.CoreV1().Namespaces().Get(ctx, nsName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	stricterNamespace := namespace.DeepCopy()
+	if stricterNamespace.Labels == nil {
+		stricterNamespace.Labels = map[string]string{}
+	}
+	stricterNamespace.Labels[enforceLabel] = level
+
+	psv, err := checkNamespace(ctx, client, stricterNamespace)
+	if err != nil || psv == nil {
+		return nil, err
+	}
+
+	return psv.PodViolations, nil
+}
+
+// checkNamespace dry-run updates the namespace and returns the violations of
+// its existing pods with their workloads resolved, or nil if there are none.
+func checkNamespace(ctx context.Context, client kubernetes.Interface, namespace *corev1.Namespace) (*PSViolation, error) {
+	// Collect the warnings of this request only, instead of setting the
+	// WarningHandler on the client, so that checks don't mix.
+	var wh *warningsMapper
+	err := retry.OnError(apiBackoff, isRetriable, func() error {
+		wh = &warningsMapper{}
+		return client.CoreV1().RESTClient().Put().
+			Resource("namespaces").
+			Name(namespace.Name).
+			VersionedParams(&metav1.UpdateOptions{DryRun: []string{metav1.DryRunAll}}, scheme.ParameterCodec).
+			Body(namespace).
+			WarningHandler(wh).
+			Do(ctx).
+			Error()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(wh.PSViolations) == 0 {
+		return nil, nil
+	}
+
+	psv := wh.PSViolations[0]
+	for _, podViolation := range psv.PodViolations {
+		if err := resolveWorkload(ctx, client, psv.Namespace, podViolation); err != nil {
+			return nil, err
+		}
+	}
+
+	return psv, nil
+}
+
+// namespaceResult is the outcome of auditing a single namespace.
+type namespaceResult struct {
+	// stricter is the namespace enforcing its audit level.
+	stricter *corev1.Namespace
+	// violations are the violations at the audit level and, if requested,
+	// at the warn level.
+	violations []*PSViolation
+	// alreadyEnforcing is set if the namespace enforces the level already,
+	// in which case it isn't checked against it again.
+	alreadyEnforcing bool
+}
+
+// clean reports whether the namespace can enforce its audit level without
+// violations. Namespaces that enforce it already aren't clean, as there is
+// nothing to apply.
+func (r *namespaceResult) clean() bool {
+	if r.alreadyEnforcing {
+		return false
+	}
+
+	for _, psv := range r.violations {
+		if psv.Mechanism == mechanismEnforce {
+			return false
+		}
+	}
+
+	return true
+}
+
+// auditNamespace checks the namespace when enforcing its audit level and, if
+// includeWarn is set, its warn level.
+func auditNamespace(ctx context.Context, client kubernetes.Interface, namespace *corev1.Namespace, includeWarn bool, targets *levelTargets) (*namespaceResult, error) {
+	stricter, defaulted := mapAuditToEnforce(namespace, targets)
+	result := &namespaceResult{
+		stricter: stricter,
+	}
+	labelChanges := labelDiff(namespace, result.stricter)
+
+	// The dry-run of an unchanged enforce label is a no-op, skip it. Pods
+	// created before the label was set aren't rechecked this way, but
+	// PodSecurity warned about them when it was.
+	result.alreadyEnforcing = namespace.Labels[enforceLabel] == result.stricter.Labels[enforceLabel]
+	if !result.alreadyEnforcing {
+		psv, err := checkNamespace(ctx, client, result.stricter)
+		if err != nil {
+			return nil, err
+		}
+		if psv != nil {
+			psv.Mechanism = mechanismEnforce
+			psv.LabelChanges = labelChanges
+			psv.AuditDefaulted = defaulted
+			result.violations = append(result.violations, psv)
+		}
+	}
+
+	// Gather the violations the warn level would produce by dry-run
+	// enforcing it, as changes to the warn label alone aren't checked
+	// against existing pods.
+	if !includeWarn {
+		return result, nil
+	}
+
+	warnNamespace := mapWarnToEnforce(namespace)
+	if warnNamespace == nil {
+		return result, nil
+	}
+
+	psv, err := checkNamespace(ctx, client, warnNamespace)
+	if err != nil {
+		return nil, err
+	}
+	// The warn level is only dry-run enforced, --apply-clean never sets it,
+	// so there are no label changes to report.
+	if psv != nil {
+		psv.Mechanism = mechanismWarn
+		result.violations = append(result.violations, psv)
+	}
+
+	return result, nil
+}
+
+// auditNamespaces audits the namespaces selected by filter with workers
+// parallel workers and passes each result to handle as soon as the results
+// of all namespaces listed before it are handled, so that results stream in
+// the order the namespaces were listed. Handle is never called concurrently.
+// The first error, from auditing or from handle, stops the audit.
+func auditNamespaces(
+	ctx context.Context,
+	client kubernetes.Interface,
+	filter *namespaceFilter,
+	includeWarn bool,
+	targets *levelTargets,
+	workers int,
+	p *progress,
+	handle func(*namespaceResult) error,
+) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type job struct {
+		index     int
+		namespace *corev1.Namespace
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		pending  = map[int]*namespaceResult{}
+		next     int
+		firstErr error
+		jobs     = make(chan job)
+	)
+
+	// fail records the first error and stops the audit. It must be called
+	// with mu held.
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				result, err := auditNamespace(ctx, client, j.namespace, includeWarn, targets)
+
+				mu.Lock()
+				if err != nil {
+					fail(fmt.Errorf("error auditing namespace %s: %w", j.namespace.Name, err))
+				}
+				pending[j.index] = result
+				for firstErr == nil {
+					result, ok := pending[next]
+					if !ok {
+						break
+					}
+					delete(pending, next)
+					next++
+
+					if err := handle(result); err != nil {
+						fail(err)
+					}
+				}
+				mu.Unlock()
+
+				p.advance()
+			}
+		}()
+	}
+
+	var listed int
+	listErr := filter.forEachPage(ctx, client, func(page []*corev1.Namespace, remaining int64) error {
+		p.setTotal(int64(listed+len(page)) + remaining)
+
+		for _, namespace := range page {
+			select {
+			case jobs <- job{index: listed, namespace: namespace}:
+				listed++
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		return nil
+	})
+	close(jobs)
+	wg.Wait()
+	p.finish()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	return listErr
+}
@@ -0,0 +1,148 @@
+package audit
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/ibihim/kube-plays/pkg/sccgen"
+)
+
+// ContainerReport lists the controls a single container violates.
+type ContainerReport struct {
+	Name     string   `json:"name"`
+	Controls []string `json:"controls"`
+}
+
+// containerBreakdown attributes the violations of a pod to the containers
+// that cause them. Controls that the warning names containers for are taken
+// as is, the others are cross-referenced against the securityContexts of the
+// pod and its containers. Pod-level controls, like host namespaces or
+// volumes, aren't attributed to any container.
+func containerBreakdown(spec *corev1.PodSpec, violations []string) []ContainerReport {
+	var (
+		reports []ContainerReport
+		index   = map[string]int{}
+	)
+	attribute := func(container, control string) {
+		i, ok := index[container]
+		if !ok {
+			i = len(reports)
+			index[container] = i
+			reports = append(reports, ContainerReport{Name: container})
+		}
+		reports[i].Controls = sccgen.AppendUnique(reports[i].Controls, control)
+	}
+
+	for _, text := range violations {
+		violation := ParseViolation(text)
+
+		containers := violation.Containers
+		if len(containers) == 0 {
+			containers = violatingContainers(spec, violation.Control)
+		}
+		for _, container := range containers {
+			attribute(container, violation.Control)
+		}
+	}
+
+	return reports
+}
+
+// violatingContainers returns the containers of the pod whose effective
+// securityContext violates the control.
+func violatingContainers(spec *corev1.PodSpec, control string) []string {
+	violates := containerCheck(spec.SecurityContext, control)
+	if violates == nil {
+		return nil
+	}
+
+	var names []string
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for _, container := range containers {
+			if violates(container) {
+				names = append(names, container.Name)
+			}
+		}
+	}
+
+	return names
+}
+
+// containerCheck returns a function that reports whether a container
+// violates the control, taking the pod's securityContext into account where
+// containers inherit from it. It returns nil for pod-level controls.
+func containerCheck(psc *corev1.PodSecurityContext, control string) func(corev1.Container) bool {
+	if psc == nil {
+		psc = &corev1.PodSecurityContext{}
+	}
+	sc := func(container corev1.Container) *corev1.SecurityContext {
+		if container.SecurityContext == nil {
+			return &corev1.SecurityContext{}
+		}
+		return container.SecurityContext
+	}
+
+	switch control {
+	case "privileged":
+		return func(c corev1.Container) bool {
+			return sc(c).Privileged != nil && *sc(c).Privileged
+		}
+	case "allowPrivilegeEscalation != false":
+		return func(c corev1.Container) bool {
+			return sc(c).AllowPrivilegeEscalation == nil || *sc(c).AllowPrivilegeEscalation
+		}
+	case "unrestricted capabilities":
+		return func(c corev1.Container) bool {
+			if capabilities := sc(c).Capabilities; capabilities != nil {
+				for _, drop := range capabilities.Drop {
+					if drop == "ALL" {
+						return false
+					}
+				}
+			}
+			return true
+		}
+	case "non-default capabilities":
+		return func(c corev1.Container) bool {
+			return sc(c).Capabilities != nil && len(sc(c).Capabilities.Add) > 0
+		}
+	case "runAsNonRoot != true":
+		return func(c corev1.Container) bool {
+			runAsNonRoot := psc.RunAsNonRoot
+			if sc(c).RunAsNonRoot != nil {
+				runAsNonRoot = sc(c).RunAsNonRoot
+			}
+			return runAsNonRoot == nil || !*runAsNonRoot
+		}
+	case "runAsUser=0":
+		return func(c corev1.Container) bool {
+			runAsUser := psc.RunAsUser
+			if sc(c).RunAsUser != nil {
+				runAsUser = sc(c).RunAsUser
+			}
+			return runAsUser != nil && *runAsUser == 0
+		}
+	case "seccompProfile":
+		return func(c corev1.Container) bool {
+			profile := psc.SeccompProfile
+			if sc(c).SeccompProfile != nil {
+				profile = sc(c).SeccompProfile
+			}
+			return profile == nil || profile.Type == corev1.SeccompProfileTypeUnconfined
+		}
+	case "hostPort":
+		return func(c corev1.Container) bool {
+			for _, port := range c.Ports {
+				if port.HostPort != 0 {
+					return true
+				}
+			}
+			return false
+		}
+	case "procMount":
+		return func(c corev1.Container) bool {
+			return sc(c).ProcMount != nil && *sc(c).ProcMount != corev1.DefaultProcMount
+		}
+	default:
+		return nil
+	}
+}
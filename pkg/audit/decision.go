@@ -0,0 +1,166 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// Admission decisions of a control for a pod.
+const (
+	decisionRejected = "rejected"
+	decisionWarned   = "warned"
+	decisionOK       = "ok"
+)
+
+// baselineControls and restrictedControls are the controls of the Pod
+// Security Standards levels, named as in the admission messages. Restricted
+// includes all baseline controls.
+var (
+	baselineControls = []string{
+		"forbidden AppArmor profile",
+		"forbidden sysctls",
+		"host namespaces",
+		"hostPath volumes",
+		"hostPort",
+		"hostProcess",
+		"non-default capabilities",
+		"privileged",
+		"procMount",
+		"seLinuxOptions",
+	}
+	restrictedControls = append([]string{
+		"allowPrivilegeEscalation != false",
+		"restricted volume types",
+		"runAsNonRoot != true",
+		"runAsUser=0",
+		"seccompProfile",
+		"unrestricted capabilities",
+	}, baselineControls...)
+)
+
+// ControlDecision is the admission decision for a single control of a pod:
+// rejected by the enforced level, only warned about by the stricter warn
+// level, or ok.
+type ControlDecision struct {
+	Violation
+	Decision string `json:"decision"`
+}
+
+// ProbeDecisions dry-run creates the pod of a decoded workload and returns
+// the decision of every restricted control: rejected if enforcing level
+// rejects the pod for it, warned if only restricted warns about it, else ok.
+// Rejected pods don't get warnings, so the pod is dry-run created twice, in
+// a scratch namespace enforcing level and in one warning about restricted.
+func ProbeDecisions(ctx context.Context, client kubernetes.Interface, obj runtime.Object, level string) ([]ControlDecision, error) {
+	pod, err := podFromObject(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	enforced, err := dryRunPodInScratchNamespace(ctx, client, pod, map[string]string{enforceLabel: level})
+	if err != nil {
+		return nil, err
+	}
+	warned, err := dryRunPodInScratchNamespace(ctx, client, pod, map[string]string{warnLabel: defaultTargetLevel})
+	if err != nil {
+		return nil, err
+	}
+
+	return controlDecisions(enforced, warned), nil
+}
+
+// controlDecisions merges the violations of enforcing the level and of
+// warning about restricted into a decision per restricted control, sorted
+// by control.
+func controlDecisions(enforced, warned []*PSViolation) []ControlDecision {
+	decisions := map[string]ControlDecision{}
+	for _, control := range restrictedControls {
+		decisions[control] = ControlDecision{Violation: Violation{Control: control}, Decision: decisionOK}
+	}
+
+	record := func(psViolations []*PSViolation, mechanism, decision string) {
+		for _, psv := range psViolations {
+			if psv.Mechanism != mechanism {
+				continue
+			}
+			for _, podViolation := range psv.PodViolations {
+				for _, text := range podViolation.Violations {
+					violation := ParseViolation(text)
+					// AppArmor's control is plural if several
+					// containers violate it.
+					if violation.Control == "forbidden AppArmor profiles" {
+						violation.Control = "forbidden AppArmor profile"
+					}
+					if decisions[violation.Control].Decision == decisionRejected {
+						continue
+					}
+					decisions[violation.Control] = ControlDecision{Violation: violation, Decision: decision}
+				}
+			}
+		}
+	}
+	record(enforced, mechanismEnforce, decisionRejected)
+	record(warned, mechanismWarn, decisionWarned)
+
+	result := make([]ControlDecision, 0, len(decisions))
+	for _, decision := range decisions {
+		result = append(result, decision)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Control < result[j].Control })
+
+	return result
+}
+
+// writeDecisions prints the decisions as JSON or YAML, or as a table for the
+// other outputs.
+func writeDecisions(w io.Writer, decisions []ControlDecision, output string) error {
+	switch output {
+	case outputJSON:
+		return json.NewEncoder(w).Encode(decisions)
+	case outputYAML:
+		data, err := yaml.Marshal(decisions)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONTROL\tDECISION\tCONTAINERS\tDETAILS")
+	for _, decision := range decisions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", decision.Control, decision.Decision, strings.Join(decision.Containers, ", "), decision.Details)
+	}
+
+	return tw.Flush()
+}
+
+// probeManifestDecisions prints the decisions for the pod of the --manifest
+// workload at level.
+func probeManifestDecisions(ctx context.Context, client kubernetes.Interface, manifestPath, level, output string) error {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return err
+	}
+	obj, err := decodeManifest(data)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s: %w", manifestPath, err)
+	}
+
+	decisions, err := ProbeDecisions(ctx, client, obj, level)
+	if err != nil {
+		return err
+	}
+
+	return writeDecisions(os.Stdout, decisions, output)
+}
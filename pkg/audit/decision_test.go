@@ -0,0 +1,74 @@
+package audit
+
+import "testing"
+
+func TestControlDecisions(t *testing.T) {
+	enforced := []*PSViolation{{
+		Mechanism: mechanismEnforce,
+		Level:     "baseline:latest",
+		PodViolations: []*PodViolation{{Violations: []string{
+			`privileged (container "app" must not set securityContext.privileged=true)`,
+		}}},
+	}}
+	warned := []*PSViolation{{
+		Mechanism: mechanismWarn,
+		Level:     "restricted:latest",
+		PodViolations: []*PodViolation{{Violations: []string{
+			`privileged (container "app" must not set securityContext.privileged=true)`,
+			`runAsNonRoot != true (pod or container "app" must set securityContext.runAsNonRoot=true)`,
+			`forbidden AppArmor profiles (annotations "container.apparmor.security.beta.kubernetes.io/a", "container.apparmor.security.beta.kubernetes.io/b"="unconfined")`,
+		}}},
+	}}
+
+	decisions := map[string]ControlDecision{}
+	for _, decision := range controlDecisions(enforced, warned) {
+		decisions[decision.Control] = decision
+	}
+
+	if len(decisions) != len(restrictedControls) {
+		t.Errorf("got %d controls, want all %d restricted controls", len(decisions), len(restrictedControls))
+	}
+
+	for _, tt := range []struct {
+		name           string
+		control        string
+		wantDecision   string
+		wantContainers []string
+	}{
+		{
+			name:           "should keep the rejection of a control that is also warned about",
+			control:        "privileged",
+			wantDecision:   decisionRejected,
+			wantContainers: []string{"app"},
+		},
+		{
+			name:           "should mark controls only restricted violates as warned",
+			control:        "runAsNonRoot != true",
+			wantDecision:   decisionWarned,
+			wantContainers: []string{"app"},
+		},
+		{
+			name:         "should fold the plural AppArmor control into the singular one",
+			control:      "forbidden AppArmor profile",
+			wantDecision: decisionWarned,
+		},
+		{
+			name:         "should mark controls without violations as ok",
+			control:      "hostPath volumes",
+			wantDecision: decisionOK,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := decisions[tt.control]
+			if got.Decision != tt.wantDecision {
+				t.Errorf("got decision %q for %s, want %q", got.Decision, tt.control, tt.wantDecision)
+			}
+			if tt.wantContainers != nil && (len(got.Containers) != 1 || got.Containers[0] != tt.wantContainers[0]) {
+				t.Errorf("got containers %v for %s, want %v", got.Containers, tt.control, tt.wantContainers)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package audit
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Labels and annotations Helm sets on the objects of a release.
+const (
+	managedByLabel                 = "app.kubernetes.io/managed-by"
+	helmReleaseNameAnnotation      = "meta.helm.sh/release-name"
+	helmReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+)
+
+// helmRelease returns the name and namespace of the Helm release that
+// manages obj, or empty strings if Helm doesn't manage it.
+func helmRelease(obj metav1.Object) (string, string) {
+	if obj.GetLabels()[managedByLabel] != "Helm" {
+		return "", ""
+	}
+
+	annotations := obj.GetAnnotations()
+	return annotations[helmReleaseNameAnnotation], annotations[helmReleaseNamespaceAnnotation]
+}
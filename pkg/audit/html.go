@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"html/template"
+)
+
+// htmlReport renders the report as a self-contained HTML page without any
+// external assets, so that it can be shared as a single file.
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>PodSecurity Audit</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #eee; }
+summary { cursor: pointer; font-weight: bold; }
+details { margin: 0.3em 0; }
+</style>
+</head>
+<body>
+<h1>PodSecurity Audit</h1>
+<table>
+<tr><th>Namespaces</th><th>Workloads</th><th>Pods</th><th>Violations</th></tr>
+<tr><td>{{.Summary.Namespaces}}</td><td>{{.Summary.Workloads}}</td><td>{{.Summary.Pods}}</td><td>{{.Summary.Violations}}</td></tr>
+</table>
+{{- if .Summary.Kinds}}
+<table>
+<tr><th>Kind</th><th>Violations</th></tr>
+{{- range $kind, $violations := .Summary.Kinds}}
+<tr><td>{{$kind}}</td><td>{{$violations}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if or .Summary.Rejected .Summary.WarnedOnly}}
+<p>{{.Summary.Rejected}} pods would be rejected on create, {{.Summary.WarnedOnly}} only warned about.</p>
+{{- end}}
+{{- if .Summary.Owners}}
+<table>
+<tr><th>Owner</th><th>Violations</th></tr>
+{{- range $owner, $violations := .Summary.Owners}}
+<tr><td>{{$owner}}</td><td>{{$violations}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .NotFound}}
+<p>Not found: {{range $i, $name := .NotFound}}{{if $i}}, {{end}}<code>{{$name}}</code>{{end}}</p>
+{{- end}}
+{{- if .Clean}}
+<p>Clean: {{range $i, $name := .Clean}}{{if $i}}, {{end}}<code>{{$name}}</code>{{end}}</p>
+{{- else if .Summary.Clean}}
+<p>{{.Summary.Clean}} namespaces without violations</p>
+{{- end}}
+{{- if .AlreadyEnforcing}}
+<p>Already enforcing: {{range $i, $name := .AlreadyEnforcing}}{{if $i}}, {{end}}<code>{{$name}}</code>{{end}}</p>
+{{- end}}
+{{- if .Summary.AuditDefaulted}}
+<p>{{.Summary.AuditDefaulted}} namespaces have no audit label and were checked against <code>` + defaultTargetLevel + `</code>.</p>
+{{- end}}
+{{- range .Namespaces}}
+<h2>{{if .Cluster}}{{.Cluster}}/{{end}}{{.Namespace}}</h2>
+<p>Level: <code>{{.Level}}</code>{{if .Mechanism}} ({{.Mechanism}}){{end}}{{if .AuditDefaulted}}, defaulted without an audit label{{end}}</p>
+{{- if .Owner}}
+<p>Owner: {{.Owner}}</p>
+{{- end}}
+{{- if .LabelChanges}}
+<ul>
+{{- range .LabelChanges}}
+<li><code>{{.String}}</code></li>
+{{- end}}
+</ul>
+{{- end}}
+{{- range .Workloads}}
+<details>
+<summary>{{.Kind}} {{.Name}} (pods: {{len .Pods}}){{if .HelmRelease}} Helm: {{.HelmNamespace}}/{{.HelmRelease}}{{end}}</summary>
+<table>
+<tr><th>Pod</th><th>Violations</th><th>Containers</th></tr>
+{{- range .Pods}}
+<tr><td>{{.Name}}</td><td>{{range .Violations}}{{.}}<br>{{end}}</td><td>{{range .Containers}}<code>{{.Name}}</code>: {{range $i, $control := .Controls}}{{if $i}}, {{end}}{{$control}}{{end}}<br>{{end}}</td></tr>
+{{- end}}
+</table>
+<p>Remediation:</p>
+<ul>
+{{- range .Remediations}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+</details>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
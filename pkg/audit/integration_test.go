@@ -0,0 +1,216 @@
+//go:build integration
+
+package audit
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/yaml"
+)
+
+// client talks to the envtest apiserver started by TestMain.
+var client kubernetes.Interface
+
+// TestMain starts an apiserver with PodSecurity admission for the
+// integration tests. The binaries are taken from $KUBEBUILDER_ASSETS, e.g.
+// KUBEBUILDER_ASSETS=$(setup-envtest use -p path) go test -tags integration ./pkg/audit
+func TestMain(m *testing.M) {
+	env := &envtest.Environment{}
+	env.ControlPlane.GetAPIServer().Configure().Append("enable-admission-plugins", "PodSecurity")
+
+	config, err := env.Start()
+	if err != nil {
+		panic(err)
+	}
+
+	client, err = kubernetes.NewForConfig(config)
+	if err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+
+	if err := env.Stop(); err != nil {
+		panic(err)
+	}
+	os.Exit(code)
+}
+
+func TestAuditIntegration(t *testing.T) {
+	ctx := context.Background()
+
+	namespace := createNamespace(ctx, t, "p0t-sekurity", map[string]string{auditLabel: "restricted"})
+	if _, err := client.CoreV1().Pods(namespace).Create(ctx, privilegedPod("privileged-pod"), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("failed to create pod: %v", err)
+	}
+
+	t.Run("should report the violations of CheckNamespace", func(t *testing.T) {
+		podViolations, err := CheckNamespace(ctx, client, namespace, "baseline")
+		if err != nil {
+			t.Fatalf("failed to check namespace: %v", err)
+		}
+
+		if len(podViolations) != 1 || podViolations[0].Name != "privileged-pod" {
+			t.Fatalf("expected a violation of privileged-pod, got %+v", podViolations)
+		}
+		if podViolations[0].Pod == nil {
+			t.Errorf("expected the pod to be resolved")
+		}
+		assertControls(t, podViolations[0].Violations, "privileged")
+	})
+
+	t.Run("should audit a namespace at its audit level", func(t *testing.T) {
+		ns, err := client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get namespace: %v", err)
+		}
+
+		result, err := auditNamespace(ctx, client, ns, false, nil)
+		if err != nil {
+			t.Fatalf("failed to audit namespace: %v", err)
+		}
+
+		if result.clean() {
+			t.Fatalf("expected namespace %s to violate restricted", namespace)
+		}
+		psv := result.violations[0]
+		if psv.Level != "restricted" || psv.Mechanism != mechanismEnforce {
+			t.Errorf("expected an enforce violation of restricted, got %s %s", psv.Mechanism, psv.Level)
+		}
+		assertControls(t, psv.PodViolations[0].Violations, "privileged", "allowPrivilegeEscalation != false")
+	})
+
+	t.Run("should verify that a copy of a violating pod would be rejected", func(t *testing.T) {
+		ns, err := client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get namespace: %v", err)
+		}
+
+		result, err := auditNamespace(ctx, client, ns, false, nil)
+		if err != nil {
+			t.Fatalf("failed to audit namespace: %v", err)
+		}
+		log := slog.New(slog.NewTextHandler(io.Discard, nil))
+		if err := verifyRejections(ctx, log, client, result.violations); err != nil {
+			t.Fatalf("failed to verify rejections: %v", err)
+		}
+
+		rejected := result.violations[0].PodViolations[0].Rejected
+		if rejected == nil || !*rejected {
+			t.Errorf("expected privileged-pod to be rejected on create, got %v", rejected)
+		}
+	})
+
+	t.Run("should report the violations of a manifest", func(t *testing.T) {
+		deployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "privileged-deployment"},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{Spec: privilegedPod("").Spec},
+			},
+		}
+
+		violations, err := CheckManifest(ctx, client, deployment, "baseline")
+		if err != nil {
+			t.Fatalf("failed to check manifest: %v", err)
+		}
+
+		var controls []string
+		for _, violation := range violations {
+			controls = append(controls, violation.Control)
+		}
+		if len(controls) != 1 || controls[0] != "privileged" {
+			t.Errorf("expected a privileged violation, got %v", controls)
+		}
+	})
+
+	t.Run("should report the violations of a --manifest for an unlabeled namespace", func(t *testing.T) {
+		unlabeled := createNamespace(ctx, t, "unlabeled", nil)
+		manifestPath := filepath.Join(t.TempDir(), "pod.yaml")
+		pod := privilegedPod("privileged-pod")
+		pod.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"}
+		data, err := yaml.Marshal(pod)
+		if err != nil {
+			t.Fatalf("failed to marshal pod: %v", err)
+		}
+		if err := os.WriteFile(manifestPath, data, 0o644); err != nil {
+			t.Fatalf("failed to write manifest: %v", err)
+		}
+
+		psViolations, err := checkManifest(ctx, client, unlabeled, nil, manifestPath)
+		if err != nil {
+			t.Fatalf("failed to check manifest: %v", err)
+		}
+
+		if len(psViolations) != 1 {
+			t.Fatalf("expected a violation of restricted, got %+v", psViolations)
+		}
+		psv := psViolations[0]
+		if psv.Namespace != unlabeled || psv.Level != "restricted" {
+			t.Errorf("expected a violation of restricted in %s, got %s in %s", unlabeled, psv.Level, psv.Namespace)
+		}
+		assertControls(t, psv.PodViolations[0].Violations, "privileged", "allowPrivilegeEscalation != false")
+	})
+}
+
+// createNamespace creates a namespace with the labels and the default service
+// account, which envtest doesn't create without a controller manager.
+func createNamespace(ctx context.Context, t *testing.T, name string, labels map[string]string) string {
+	t.Helper()
+
+	_, err := client.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("failed to create namespace: %v", err)
+	}
+
+	_, err = client.CoreV1().ServiceAccounts(name).Create(ctx, &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("failed to create service account: %v", err)
+	}
+
+	return name
+}
+
+func privilegedPod(name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "app",
+				Image: "registry.k8s.io/pause:3.9",
+				SecurityContext: &corev1.SecurityContext{
+					Privileged: boolPtr(true),
+				},
+			}},
+		},
+	}
+}
+
+// assertControls fails unless the violations contain every control.
+func assertControls(t *testing.T, violations []string, controls ...string) {
+	t.Helper()
+
+	found := map[string]bool{}
+	for _, violation := range violations {
+		found[ParseViolation(violation).Control] = true
+	}
+
+	for _, control := range controls {
+		if !found[control] {
+			t.Errorf("expected a %q violation, got %v", control, violations)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// defaultTargetLevel is enforced on namespaces without an audit label and
+// without a target level.
+const defaultTargetLevel = "restricted"
+
+// levelRule targets a level for the namespace of the given name or the
+// namespaces matching the selector, as read from --levels-file.
+type levelRule struct {
+	Namespace string `json:"namespace,omitempty"`
+	Selector  string `json:"selector,omitempty"`
+	Level     string `json:"level"`
+
+	selector labels.Selector
+}
+
+// levelTargets decide the level each namespace should enforce. A nil
+// *levelTargets targets each namespace's audit level.
+type levelTargets struct {
+	// rules are consulted in order, the first match wins.
+	rules []levelRule
+	// fallback is the --target-level of namespaces no rule matches.
+	fallback string
+}
+
+// newLevelTargets reads the rules of the levels file, if any, and validates
+// them along with the fallback level. The file is a YAML list like
+//
+//   - namespace: legacy-app
+//     level: baseline
+//   - selector: tier=legacy
+//     level: baseline
+func newLevelTargets(path, fallback string) (*levelTargets, error) {
+	if fallback != "" {
+		if err := validateLevel(fallback); err != nil {
+			return nil, fmt.Errorf("invalid --target-level: %w", err)
+		}
+	}
+
+	targets := &levelTargets{fallback: fallback}
+	if path == "" {
+		return targets, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.UnmarshalStrict(data, &targets.rules); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+
+	for i := range targets.rules {
+		rule := &targets.rules[i]
+		if (rule.Namespace == "") == (rule.Selector == "") {
+			return nil, fmt.Errorf("rule %d of %s must set exactly one of namespace and selector", i+1, path)
+		}
+		if err := validateLevel(rule.Level); err != nil {
+			return nil, fmt.Errorf("rule %d of %s: %w", i+1, path, err)
+		}
+		if rule.Selector != "" {
+			rule.selector, err = labels.Parse(rule.Selector)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d of %s: invalid selector: %w", i+1, path, err)
+			}
+		}
+	}
+
+	return targets, nil
+}
+
+// level returns the level the namespace should enforce, or an empty string
+// if neither a rule nor the fallback decide it.
+func (t *levelTargets) level(namespace *corev1.Namespace) string {
+	if t == nil {
+		return ""
+	}
+
+	for _, rule := range t.rules {
+		if rule.Namespace == namespace.Name {
+			return rule.Level
+		}
+		if rule.selector != nil && rule.selector.Matches(labels.Set(namespace.Labels)) {
+			return rule.Level
+		}
+	}
+
+	return t.fallback
+}
+
+// validateLevel fails unless level is a PodSecurity level.
+func validateLevel(level string) error {
+	switch level {
+	case "privileged", "baseline", "restricted":
+		return nil
+	case "":
+		return errors.New("level is missing")
+	default:
+		return fmt.Errorf("unknown level %q, must be privileged, baseline or restricted", level)
+	}
+}
@@ -0,0 +1,230 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// scratchNamespacePrefix is the generateName of the namespaces CheckManifest
+// and the admission-config probe dry-run pods in.
+const scratchNamespacePrefix = "kube-plays-check-"
+
+// podFromManifest decodes a workload manifest and returns the pod it would
+// create.
+func podFromManifest(data []byte) (*corev1.Pod, error) {
+	obj, err := decodeManifest(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return podFromObject(obj)
+}
+
+// decodeManifest decodes a manifest of a built-in kind.
+func decodeManifest(data []byte) (runtime.Object, error) {
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(data, nil, nil)
+	return obj, err
+}
+
+// podFromObject returns the pod that a decoded workload would create.
+func podFromObject(obj runtime.Object) (*corev1.Pod, error) {
+	var (
+		meta     metav1.ObjectMeta
+		template corev1.PodTemplateSpec
+	)
+
+	switch o := obj.(type) {
+	case *corev1.Pod:
+		return o.DeepCopy(), nil
+	case *appsv1.Deployment:
+		meta, template = o.ObjectMeta, o.Spec.Template
+	case *appsv1.ReplicaSet:
+		meta, template = o.ObjectMeta, o.Spec.Template
+	case *appsv1.StatefulSet:
+		meta, template = o.ObjectMeta, o.Spec.Template
+	case *appsv1.DaemonSet:
+		meta, template = o.ObjectMeta, o.Spec.Template
+	case *batchv1.Job:
+		meta, template = o.ObjectMeta, o.Spec.Template
+	case *batchv1.CronJob:
+		meta, template = o.ObjectMeta, o.Spec.JobTemplate.Spec.Template
+	default:
+		return nil, fmt.Errorf("unsupported kind %s", obj.GetObjectKind().GroupVersionKind().Kind)
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: template.ObjectMeta,
+		Spec:       template.Spec,
+	}
+	pod.Name = meta.Name
+
+	return pod, nil
+}
+
+// checkManifest returns the PodSecurity violations the pod generated from the
+// manifest would produce in the namespace at its target level: the level
+// targets decide on, else its audit level, else restricted. The pod is
+// dry-run created in a scratch namespace enforcing that level, so the
+// namespace doesn't need to be labeled or even exist.
+func checkManifest(ctx context.Context, client kubernetes.Interface, namespace string, targets *levelTargets, manifestPath string) ([]*PSViolation, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	pod, err := podFromManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", manifestPath, err)
+	}
+
+	ns, err := client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		ns, err = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ns, _ = mapAuditToEnforce(ns, targets)
+
+	psViolations, err := dryRunPodInScratchNamespace(ctx, client, pod, map[string]string{enforceLabel: ns.Labels[enforceLabel]})
+	if err != nil {
+		return nil, err
+	}
+
+	// Report the violations against the namespace, not the scratch one.
+	for _, psv := range psViolations {
+		psv.Namespace = namespace
+		for _, podViolation := range psv.PodViolations {
+			podViolation.Pod.Namespace = namespace
+		}
+	}
+
+	return psViolations, nil
+}
+
+// dryRunPod does a server-side dry-run create of the pod and returns the
+// PodSecurity violations it would produce.
+func dryRunPod(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod) ([]*PSViolation, error) {
+	namespace := pod.Namespace
+	mechanism := mechanismWarn
+	wh := &warningsMapper{}
+	err := client.CoreV1().RESTClient().Post().
+		Namespace(namespace).
+		Resource("pods").
+		VersionedParams(&metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}}, scheme.ParameterCodec).
+		Body(pod).
+		WarningHandler(wh).
+		Do(ctx).
+		Error()
+	if apierrors.IsForbidden(err) {
+		// At the enforce level the pod is rejected instead of warned about,
+		// but the message has the same shape as the warning.
+		if i := strings.Index(err.Error(), "violates PodSecurity"); i >= 0 {
+			mechanism = mechanismEnforce
+			wh.HandleWarningHeader(299, "", "would "+err.Error()[i:])
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Pod warnings don't name the namespace or the pod, fill them in.
+	for _, psv := range wh.PSViolations {
+		psv.Namespace = namespace
+		psv.Mechanism = mechanism
+		for _, podViolation := range psv.PodViolations {
+			podViolation.Name = pod.Name
+			podViolation.Pod = pod
+		}
+	}
+
+	return wh.PSViolations, nil
+}
+
+// CheckManifest reports the PodSecurity violations of the pod a decoded
+// workload would create, as if it were created in a namespace that enforces
+// level. The pod is dry-run created in a scratch namespace, which is deleted
+// afterwards.
+func CheckManifest(ctx context.Context, client kubernetes.Interface, obj runtime.Object, level string) ([]Violation, error) {
+	pod, err := podFromObject(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	psViolations, err := dryRunPodInScratchNamespace(ctx, client, pod, map[string]string{enforceLabel: level})
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []Violation
+	for _, psv := range psViolations {
+		for _, podViolation := range psv.PodViolations {
+			for _, text := range podViolation.Violations {
+				violations = append(violations, ParseViolation(text))
+			}
+		}
+	}
+
+	return violations, nil
+}
+
+// dryRunPodInScratchNamespace dry-run creates the pod in a scratch namespace
+// with the labels and returns the PodSecurity violations it would produce.
+func dryRunPodInScratchNamespace(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod, labels map[string]string) ([]*PSViolation, error) {
+	serviceAccount := pod.Spec.ServiceAccountName
+	if serviceAccount == "" {
+		serviceAccount = "default"
+	}
+	namespace, cleanUp, err := createScratchNamespace(ctx, client, labels, serviceAccount)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanUp()
+
+	pod = pod.DeepCopy()
+	pod.Namespace = namespace.Name
+
+	return dryRunPod(ctx, client, pod)
+}
+
+// createScratchNamespace creates a namespace with the labels and the service
+// account to dry-run pods in. The returned func deletes the namespace again,
+// even if ctx got cancelled in the meantime.
+func createScratchNamespace(ctx context.Context, client kubernetes.Interface, labels map[string]string, serviceAccount string) (*corev1.Namespace, func(), error) {
+	namespace, err := client.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: scratchNamespacePrefix,
+			Labels:       labels,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create scratch namespace: %w", err)
+	}
+	cleanUp := func() {
+		_ = client.CoreV1().Namespaces().Delete(context.WithoutCancel(ctx), namespace.Name, metav1.DeleteOptions{})
+	}
+
+	// The ServiceAccount admission rejects pods whose service account doesn't
+	// exist, before PodSecurity gets to see them.
+	_, err = client.CoreV1().ServiceAccounts(namespace.Name).Create(ctx, &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: serviceAccount},
+	}, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		cleanUp()
+		return nil, nil, fmt.Errorf("failed to create service account in scratch namespace: %w", err)
+	}
+
+	return namespace, cleanUp, nil
+}
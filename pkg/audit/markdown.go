@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"strings"
+	"text/template"
+)
+
+// markdownReport renders the report as a Markdown document that can be
+// pasted into a GitHub issue or pull request.
+var markdownReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"cell": markdownCell,
+}).Parse(`# PodSecurity Audit
+
+## Summary
+
+| Namespaces | Workloads | Pods | Violations |
+|---|---|---|---|
+| {{.Summary.Namespaces}} | {{.Summary.Workloads}} | {{.Summary.Pods}} | {{.Summary.Violations}} |
+{{- if .Summary.Kinds}}
+
+| Kind | Violations |
+|---|---|
+{{- range $kind, $violations := .Summary.Kinds}}
+| {{$kind}} | {{$violations}} |
+{{- end}}
+{{- end}}
+{{- if or .Summary.Rejected .Summary.WarnedOnly}}
+
+{{.Summary.Rejected}} pods would be rejected on create, {{.Summary.WarnedOnly}} only warned about.
+{{- end}}
+{{- if .Summary.Owners}}
+
+| Owner | Violations |
+|---|---|
+{{- range $owner, $violations := .Summary.Owners}}
+| {{cell $owner}} | {{$violations}} |
+{{- end}}
+{{- end}}
+{{- if .NotFound}}
+
+> Not found: {{range $i, $name := .NotFound}}{{if $i}}, {{end}}` + "`{{$name}}`" + `{{end}}
+{{- end}}
+{{- if .Clean}}
+
+> Clean: {{range $i, $name := .Clean}}{{if $i}}, {{end}}` + "`{{$name}}`" + `{{end}}
+{{- else if .Summary.Clean}}
+
+> {{.Summary.Clean}} namespaces without violations
+{{- end}}
+{{- if .AlreadyEnforcing}}
+
+> Already enforcing: {{range $i, $name := .AlreadyEnforcing}}{{if $i}}, {{end}}` + "`{{$name}}`" + `{{end}}
+{{- end}}
+{{- if .Summary.AuditDefaulted}}
+
+> {{.Summary.AuditDefaulted}} namespaces have no audit label and were checked against ` + "`" + defaultTargetLevel + "`" + `.
+{{- end}}
+{{range .Namespaces}}
+## {{if .Cluster}}{{cell .Cluster}}/{{end}}{{.Namespace}}
+
+Level: ` + "`{{.Level}}`" + `{{if .Mechanism}} ({{.Mechanism}}){{end}}{{if .AuditDefaulted}}, defaulted without an audit label{{end}}
+{{- if .Owner}}
+
+Owner: {{cell .Owner}}
+{{- end}}
+{{- if .LabelChanges}}
+{{range .LabelChanges}}
+- ` + "`{{.String}}`" + `
+{{- end}}
+{{- end}}
+
+| Workload | Pods | Violations | Remediation |
+|---|---|---|---|
+{{- range .Workloads}}
+| {{cell .Kind}} {{cell .Name}}{{if .HelmRelease}}<br>Helm: {{cell .HelmNamespace}}/{{cell .HelmRelease}}{{end}} | {{range $i, $pod := .Pods}}{{if $i}}<br>{{end}}{{cell $pod.Name}}{{end}} | {{range $i, $violation := .Violations}}{{if $i}}<br>{{end}}{{cell $violation}}{{end}} | {{range $i, $remediation := .Remediations}}{{if $i}}<br>{{end}}{{cell $remediation}}{{end}} |
+{{- end}}
+{{end}}`))
+
+// markdownCell escapes the text so that it doesn't break a table cell.
+func markdownCell(text string) string {
+	text = strings.ReplaceAll(text, "|", `\|`)
+	return strings.ReplaceAll(text, "\n", " ")
+}
@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics counts the violations found by the audit. A nil *metrics counts
+// nothing, so callers don't need to check whether metrics are enabled.
+type metrics struct {
+	violations *prometheus.CounterVec
+}
+
+// newMetrics creates the audit metrics and registers them with reg.
+func newMetrics(reg prometheus.Registerer) *metrics {
+	m := &metrics{
+		violations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "psa_violations_total",
+			Help: "Number of PodSecurity violations found, per namespace, level and control.",
+		}, []string{"namespace", "level", "control"}),
+	}
+	reg.MustRegister(m.violations)
+
+	return m
+}
+
+// observe counts every violation of every pod in psv.
+func (m *metrics) observe(psv *PSViolation) {
+	if m == nil {
+		return
+	}
+
+	for _, podViolation := range psv.PodViolations {
+		for _, violation := range podViolation.Violations {
+			m.observeViolation(psv.Namespace, psv.Level, violation)
+		}
+	}
+}
+
+// observeViolation counts a single violation.
+func (m *metrics) observeViolation(namespace, level, violation string) {
+	if m == nil {
+		return
+	}
+
+	m.violations.WithLabelValues(namespace, level, ParseViolation(violation).Control).Inc()
+}
+
+// serveMetrics serves the metrics of reg on addr under /metrics until ctx is
+// cancelled.
+func serveMetrics(ctx context.Context, log *slog.Logger, addr string, reg *prometheus.Registry) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Error("Error shutting down metrics server", "err", err)
+		}
+	}()
+
+	go func() {
+		log.Info("Serving metrics", "addr", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("Error serving metrics", "addr", addr, "err", err)
+		}
+	}()
+}
+
+// waitForScrapes keeps the metrics of a finished audit available until ctx is
+// cancelled.
+func waitForScrapes(ctx context.Context, log *slog.Logger) {
+	log.Info("Audit finished, serving metrics until interrupted")
+	<-ctx.Done()
+}
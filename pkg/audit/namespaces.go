@@ -0,0 +1,405 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/ibihim/kube-plays/pkg/cmdutil"
+)
+
+// PodSecurity admission namespace labels.
+const (
+	enforceLabel = "pod-security.kubernetes.io/enforce"
+	auditLabel   = "pod-security.kubernetes.io/audit"
+	warnLabel    = "pod-security.kubernetes.io/warn"
+
+	// labelSyncLabel turns OpenShift's PodSecurity label synchronization
+	// on or off for a namespace.
+	labelSyncLabel = "security.openshift.io/scc.podSecurityLabelSync"
+)
+
+// namespaceFilter selects the namespaces to look at.
+type namespaceFilter struct {
+	selector        string
+	excludePrefixes []string
+	pageSize        int64
+	namespacesFile  string
+
+	// names are the namespaces listed in namespacesFile, in order, and
+	// allowed holds the same names for lookups. Both are nil without a file.
+	names   []string
+	allowed map[string]bool
+	// notFound are the listed namespaces that don't exist.
+	notFound []string
+}
+
+func (f *namespaceFilter) addFlags(fs *pflag.FlagSet) {
+	fs.StringVarP(&f.selector, "selector", "l", "", "label selector of the namespaces to look at")
+	fs.StringSliceVar(&f.excludePrefixes, "exclude-prefixes", nil, "skip namespaces whose names start with any of these prefixes")
+	fs.Int64Var(&f.pageSize, "page-size", 0, "list namespaces in pages of this size, 0 lists them all at once")
+	fs.StringVar(&f.namespacesFile, "namespaces-file", "", "only look at the namespaces listed in this file, one per line; blank lines and # comments are skipped")
+}
+
+// load reads the namespaces of --namespaces-file, if set.
+func (f *namespaceFilter) load() error {
+	if f.namespacesFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(f.namespacesFile)
+	if err != nil {
+		return fmt.Errorf("error reading namespaces file: %w", err)
+	}
+
+	f.names = []string{}
+	f.allowed = map[string]bool{}
+	for _, line := range strings.Split(string(data), "\n") {
+		name := strings.TrimSpace(line)
+		if name == "" || strings.HasPrefix(name, "#") || f.allowed[name] {
+			continue
+		}
+		f.names = append(f.names, name)
+		f.allowed[name] = true
+	}
+
+	return nil
+}
+
+// forEach calls fn for every namespace that matches the selector and doesn't
+// start with an excluded prefix. Namespaces are listed page by page and each
+// page is processed as it arrives.
+func (f *namespaceFilter) forEach(ctx context.Context, client kubernetes.Interface, fn func(*corev1.Namespace) error) error {
+	return f.forEachPage(ctx, client, func(page []*corev1.Namespace, _ int64) error {
+		for _, namespace := range page {
+			if err := fn(namespace); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// forEachPage calls fn for every page of namespaces that forEach would visit.
+// Remaining is the apiserver's estimate of how many namespaces are left to
+// list after the page, before excluding any.
+func (f *namespaceFilter) forEachPage(ctx context.Context, client kubernetes.Interface, fn func(page []*corev1.Namespace, remaining int64) error) error {
+	if f.names != nil {
+		return f.forEachListedPage(ctx, client, fn)
+	}
+
+	opts := metav1.ListOptions{
+		LabelSelector: f.selector,
+		Limit:         f.pageSize,
+	}
+
+	for {
+		namespaceList, err := client.CoreV1().Namespaces().List(ctx, opts)
+		if err != nil {
+			return err
+		}
+
+		page := make([]*corev1.Namespace, 0, len(namespaceList.Items))
+		for i := range namespaceList.Items {
+			if !f.excluded(namespaceList.Items[i].Name) {
+				page = append(page, &namespaceList.Items[i])
+			}
+		}
+
+		var remaining int64
+		if namespaceList.RemainingItemCount != nil {
+			remaining = *namespaceList.RemainingItemCount
+		}
+
+		if err := fn(page, remaining); err != nil {
+			return err
+		}
+
+		if namespaceList.Continue == "" {
+			return nil
+		}
+		opts.Continue = namespaceList.Continue
+	}
+}
+
+// forEachListedPage gets the namespaces of --namespaces-file one by one and
+// calls fn for them as a single page. Namespaces that don't exist are
+// recorded in notFound.
+func (f *namespaceFilter) forEachListedPage(ctx context.Context, client kubernetes.Interface, fn func(page []*corev1.Namespace, remaining int64) error) error {
+	selector, err := labels.Parse(f.selector)
+	if err != nil {
+		return err
+	}
+
+	f.notFound = nil
+	page := make([]*corev1.Namespace, 0, len(f.names))
+	for _, name := range f.names {
+		namespace, err := client.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			f.notFound = append(f.notFound, name)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if selector.Matches(labels.Set(namespace.Labels)) && !f.excluded(name) {
+			page = append(page, namespace)
+		}
+	}
+
+	return fn(page, 0)
+}
+
+// list returns all namespaces that forEach would visit.
+func (f *namespaceFilter) list(ctx context.Context, client kubernetes.Interface) ([]corev1.Namespace, error) {
+	var namespaces []corev1.Namespace
+	err := f.forEach(ctx, client, func(namespace *corev1.Namespace) error {
+		namespaces = append(namespaces, *namespace)
+		return nil
+	})
+
+	return namespaces, err
+}
+
+// logNotFound warns about the listed namespaces that don't exist, so that
+// they aren't skipped silently.
+func (f *namespaceFilter) logNotFound(log *slog.Logger) {
+	for _, name := range f.notFound {
+		log.Warn("Namespace not found", "namespace", name)
+	}
+}
+
+func (f *namespaceFilter) excluded(name string) bool {
+	if f.allowed != nil && !f.allowed[name] {
+		return true
+	}
+
+	for _, prefix := range f.excludePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newLabelsCommand returns a command that lists the PodSecurity labels of
+// the namespaces without changing anything.
+func newLabelsCommand(flags *cmdutil.Flags, filter *namespaceFilter) *cobra.Command {
+	return &cobra.Command{
+		Use:   "labels",
+		Short: "List the PodSecurity labels of namespaces and flag those without an enforce label",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := flags.Clientset()
+			if err != nil {
+				return err
+			}
+
+			namespaces, err := filter.list(cmd.Context(), client)
+			if err != nil {
+				return err
+			}
+			filter.logNotFound(flags.Logger())
+
+			return printLabels(namespaces)
+		},
+	}
+}
+
+// printLabels prints a table of the warn, audit and enforce labels of the
+// namespaces.
+func printLabels(namespaces []corev1.Namespace) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tWARN\tAUDIT\tENFORCE\t")
+
+	missing := 0
+	for _, namespace := range namespaces {
+		enforce := namespace.Labels[enforceLabel]
+		if enforce == "" {
+			enforce = "<missing>"
+			missing++
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n",
+			namespace.Name,
+			labelOrNone(namespace.Labels[warnLabel]),
+			labelOrNone(namespace.Labels[auditLabel]),
+			enforce,
+		)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%d of %d namespaces have no enforce label\n", missing, len(namespaces))
+
+	return nil
+}
+
+func labelOrNone(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
+
+// levelStrictness orders the PodSecurity levels from least to most strict.
+var levelStrictness = map[string]int{
+	"privileged": 0,
+	"baseline":   1,
+	"restricted": 2,
+}
+
+// newGapCommand returns a command that compares the audit and enforce levels
+// of the namespaces without changing anything.
+func newGapCommand(flags *cmdutil.Flags, filter *namespaceFilter) *cobra.Command {
+	return &cobra.Command{
+		Use:   "gap",
+		Short: "Compare the audit and enforce levels of namespaces and flag where enforce is weaker",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := flags.Clientset()
+			if err != nil {
+				return err
+			}
+
+			namespaces, err := filter.list(cmd.Context(), client)
+			if err != nil {
+				return err
+			}
+			filter.logNotFound(flags.Logger())
+
+			return printGap(namespaces)
+		},
+	}
+}
+
+// printGap prints the audit and enforce level of each namespace side by side
+// and marks the namespaces whose enforce level is weaker than their audit
+// level. Those are the ones most ready to be tightened.
+func printGap(namespaces []corev1.Namespace) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tAUDIT\tENFORCE\tGAP\t")
+
+	weaker := 0
+	for _, namespace := range namespaces {
+		audit := namespace.Labels[auditLabel]
+		enforce := namespace.Labels[enforceLabel]
+
+		gap := ""
+		if enforceWeakerThanAudit(audit, enforce) {
+			gap = "enforce weaker than audit"
+			weaker++
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", namespace.Name, labelOrNone(audit), labelOrNone(enforce), gap)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%d of %d namespaces enforce a weaker level than they audit\n", weaker, len(namespaces))
+
+	return nil
+}
+
+// enforceWeakerThanAudit reports whether the enforce level is less strict
+// than the audit level. A missing enforce label enforces privileged, a
+// missing or unknown audit label has nothing to compare against.
+func enforceWeakerThanAudit(audit, enforce string) bool {
+	auditStrictness, ok := levelStrictness[audit]
+	if !ok {
+		return false
+	}
+
+	if enforce == "" {
+		enforce = "privileged"
+	}
+	enforceStrictness, ok := levelStrictness[enforce]
+	if !ok {
+		return false
+	}
+
+	return enforceStrictness < auditStrictness
+}
+
+// newSyncerCommand returns a command that lists the namespaces whose
+// PodSecurity labels aren't synchronized by OpenShift.
+func newSyncerCommand(flags *cmdutil.Flags, filter *namespaceFilter) *cobra.Command {
+	return &cobra.Command{
+		Use:   "syncer",
+		Short: "List namespaces that OpenShift's PodSecurity label syncer ignores",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := flags.Clientset()
+			if err != nil {
+				return err
+			}
+
+			namespaces, err := filter.list(cmd.Context(), client)
+			if err != nil {
+				return err
+			}
+			filter.logNotFound(flags.Logger())
+
+			return printUnsynced(namespaces)
+		},
+	}
+}
+
+// printUnsynced prints the namespaces that require manual PodSecurity
+// management, because the label syncer is disabled for them.
+func printUnsynced(namespaces []corev1.Namespace) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tREASON\t")
+
+	unsynced := 0
+	for _, namespace := range namespaces {
+		reason := syncerDisabledReason(&namespace)
+		if reason == "" {
+			continue
+		}
+
+		unsynced++
+		fmt.Fprintf(w, "%s\t%s\t\n", namespace.Name, reason)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%d of %d namespaces require manual PodSecurity management\n", unsynced, len(namespaces))
+
+	return nil
+}
+
+// syncerDisabledReason returns why the label syncer ignores the namespace, or
+// an empty string if it synchronizes it. The syncer skips openshift-
+// prefixed namespaces unless they opt in, and any namespace that opts out.
+func syncerDisabledReason(namespace *corev1.Namespace) string {
+	switch sync := namespace.Labels[labelSyncLabel]; {
+	case sync == "false":
+		return labelSyncLabel + "=false"
+	case sync != "true" && strings.HasPrefix(namespace.Name, "openshift-"):
+		return "openshift- prefixed namespace"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// maxOwnerDepth bounds the owner chain, in case owner references form a
+// cycle.
+const maxOwnerDepth = 10
+
+// ownerGetter gets a controller object by name.
+type ownerGetter func(ctx context.Context, client kubernetes.Interface, namespace, name string) (runtime.Object, error)
+
+// ownerGetters are the controller kinds whose owner references are followed.
+var ownerGetters = map[schema.GroupVersionKind]ownerGetter{
+	appsv1.SchemeGroupVersion.WithKind("Deployment"): func(ctx context.Context, client kubernetes.Interface, namespace, name string) (runtime.Object, error) {
+		return client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	},
+	appsv1.SchemeGroupVersion.WithKind("ReplicaSet"): func(ctx context.Context, client kubernetes.Interface, namespace, name string) (runtime.Object, error) {
+		return client.AppsV1().ReplicaSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	},
+	appsv1.SchemeGroupVersion.WithKind("StatefulSet"): func(ctx context.Context, client kubernetes.Interface, namespace, name string) (runtime.Object, error) {
+		return client.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	},
+	appsv1.SchemeGroupVersion.WithKind("DaemonSet"): func(ctx context.Context, client kubernetes.Interface, namespace, name string) (runtime.Object, error) {
+		return client.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	},
+	batchv1.SchemeGroupVersion.WithKind("Job"): func(ctx context.Context, client kubernetes.Interface, namespace, name string) (runtime.Object, error) {
+		return client.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	},
+	batchv1.SchemeGroupVersion.WithKind("CronJob"): func(ctx context.Context, client kubernetes.Interface, namespace, name string) (runtime.Object, error) {
+		return client.BatchV1().CronJobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	},
+}
+
+// TopOwner returns the kind and name of the top-most controller of the pod.
+// A bare pod is its own top owner.
+func TopOwner(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod) (string, string, error) {
+	kind, name, _, err := resolveTopOwner(ctx, client, pod.Namespace, pod)
+	return kind, name, err
+}
+
+// resolveTopOwner follows the controller owner references of obj up to the
+// top-most controller and returns its kind, name and object. An object
+// without a controller is its own top owner. If an owner is gone or of a
+// kind without a getter, its kind and name are returned with a nil object.
+func resolveTopOwner(ctx context.Context, client kubernetes.Interface, namespace string, obj runtime.Object) (string, string, runtime.Object, error) {
+	kinds, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil {
+		return "", "", nil, err
+	}
+	kind := kinds[0].Kind
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	for depth := 0; depth < maxOwnerDepth; depth++ {
+		owner := metav1.GetControllerOf(accessor)
+		if owner == nil {
+			return kind, accessor.GetName(), obj, nil
+		}
+
+		get, ok := ownerGetters[schema.FromAPIVersionAndKind(owner.APIVersion, owner.Kind)]
+		if !ok {
+			return owner.Kind, owner.Name, nil, nil
+		}
+
+		obj, err = get(ctx, client, namespace, owner.Name)
+		if apierrors.IsNotFound(err) {
+			return owner.Kind, owner.Name, nil, nil
+		}
+		if err != nil {
+			return "", "", nil, err
+		}
+
+		accessor, err = meta.Accessor(obj)
+		if err != nil {
+			return "", "", nil, err
+		}
+		kind = owner.Kind
+	}
+
+	return "", "", nil, fmt.Errorf("owner chain of %s %s/%s is deeper than %d", kinds[0].Kind, namespace, accessor.GetName(), maxOwnerDepth)
+}
@@ -0,0 +1,250 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestResolveWorkload(t *testing.T) {
+	const namespace = "p0t-sekurity"
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "privileged-deployment", Namespace: namespace},
+	}
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "privileged-deployment-7d4b9c6f5",
+			Namespace:       namespace,
+			OwnerReferences: []metav1.OwnerReference{controllerRef("Deployment", deployment.Name)},
+		},
+	}
+	orphanedReplicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "orphaned-replicaset", Namespace: namespace},
+	}
+
+	for _, tt := range []struct {
+		name           string
+		pod            *corev1.Pod
+		objects        []runtime.Object
+		wantDeployment string
+		wantKind       string
+		wantName       string
+		wantObject     bool
+	}{
+		{
+			name:           "should resolve a pod owned by a Deployment",
+			pod:            pod(namespace, "direct", controllerRef("Deployment", deployment.Name)),
+			objects:        []runtime.Object{deployment},
+			wantDeployment: deployment.Name,
+			wantKind:       "Deployment",
+			wantName:       deployment.Name,
+			wantObject:     true,
+		},
+		{
+			name:           "should resolve a pod owned by a ReplicaSet to its Deployment",
+			pod:            pod(namespace, "indirect", controllerRef("ReplicaSet", replicaSet.Name)),
+			objects:        []runtime.Object{deployment, replicaSet},
+			wantDeployment: deployment.Name,
+			wantKind:       "Deployment",
+			wantName:       deployment.Name,
+			wantObject:     true,
+		},
+		{
+			name:     "should not resolve a bare pod",
+			pod:      pod(namespace, "bare"),
+			wantKind: "Pod",
+			wantName: "bare",
+		},
+		{
+			name:     "should name the owner of a pod whose owner is gone",
+			pod:      pod(namespace, "missing-owner", controllerRef("ReplicaSet", replicaSet.Name)),
+			wantKind: "ReplicaSet",
+			wantName: replicaSet.Name,
+		},
+		{
+			name:       "should not resolve a pod whose ReplicaSet has no owner",
+			pod:        pod(namespace, "orphaned", controllerRef("ReplicaSet", orphanedReplicaSet.Name)),
+			objects:    []runtime.Object{orphanedReplicaSet},
+			wantKind:   "ReplicaSet",
+			wantName:   orphanedReplicaSet.Name,
+			wantObject: true,
+		},
+		{
+			name:     "should not resolve a pod owned by another kind",
+			pod:      pod(namespace, "daemon", controllerRef("DaemonSet", "node-exporter")),
+			objects:  []runtime.Object{deployment},
+			wantKind: "DaemonSet",
+			wantName: "node-exporter",
+		},
+		{
+			name: "should name the owner of a pod owned by an unknown kind",
+			pod: pod(namespace, "build", metav1.OwnerReference{
+				APIVersion: "build.openshift.io/v1",
+				Kind:       "Build",
+				Name:       "app-1",
+				Controller: boolPtr(true),
+			}),
+			wantKind: "Build",
+			wantName: "app-1",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := fake.NewSimpleClientset(append(tt.objects, tt.pod)...)
+			podViolation := &PodViolation{Name: tt.pod.Name}
+
+			if err := resolveWorkload(context.Background(), client, namespace, podViolation); err != nil {
+				t.Fatalf("failed to resolve workload: %v", err)
+			}
+
+			if podViolation.Pod == nil || podViolation.Pod.Name != tt.pod.Name {
+				t.Errorf("expected pod %s to be attached, got %v", tt.pod.Name, podViolation.Pod)
+			}
+
+			var gotDeployment string
+			if podViolation.Deployment != nil {
+				gotDeployment = podViolation.Deployment.Name
+			}
+			if gotDeployment != tt.wantDeployment {
+				t.Errorf("expected deployment %q, got %q", tt.wantDeployment, gotDeployment)
+			}
+
+			if podViolation.WorkloadKind != tt.wantKind || podViolation.WorkloadName != tt.wantName {
+				t.Errorf("expected workload %s/%s, got %s/%s", tt.wantKind, tt.wantName, podViolation.WorkloadKind, podViolation.WorkloadName)
+			}
+			if (podViolation.WorkloadObject != nil) != tt.wantObject {
+				t.Errorf("expected workload object %t, got %v", tt.wantObject, podViolation.WorkloadObject)
+			}
+		})
+	}
+}
+
+func TestResolveTopOwner(t *testing.T) {
+	const namespace = "p0t-sekurity"
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: namespace},
+	}
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "web-7d4b9c6f5",
+			Namespace:       namespace,
+			OwnerReferences: []metav1.OwnerReference{controllerRef("Deployment", deployment.Name)},
+		},
+	}
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: namespace},
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "backup-28467360",
+			Namespace:       namespace,
+			OwnerReferences: []metav1.OwnerReference{batchControllerRef("CronJob", cronJob.Name)},
+		},
+	}
+
+	for _, tt := range []struct {
+		name     string
+		pod      *corev1.Pod
+		objects  []runtime.Object
+		wantKind string
+		wantName string
+		wantObj  bool
+	}{
+		{
+			name:     "should walk a Pod, ReplicaSet, Deployment chain to the Deployment",
+			pod:      pod(namespace, "web-7d4b9c6f5-x2x9z", controllerRef("ReplicaSet", replicaSet.Name)),
+			objects:  []runtime.Object{deployment, replicaSet},
+			wantKind: "Deployment",
+			wantName: deployment.Name,
+			wantObj:  true,
+		},
+		{
+			name:     "should walk a Pod, Job, CronJob chain to the CronJob",
+			pod:      pod(namespace, "backup-28467360-q8k2m", batchControllerRef("Job", job.Name)),
+			objects:  []runtime.Object{cronJob, job},
+			wantKind: "CronJob",
+			wantName: cronJob.Name,
+			wantObj:  true,
+		},
+		{
+			name:     "should return a bare pod as its own top owner",
+			pod:      pod(namespace, "bare"),
+			wantKind: "Pod",
+			wantName: "bare",
+			wantObj:  true,
+		},
+		{
+			name:     "should stop at an owner that is gone",
+			pod:      pod(namespace, "web-7d4b9c6f5-x2x9z", controllerRef("ReplicaSet", replicaSet.Name)),
+			objects:  []runtime.Object{deployment},
+			wantKind: "ReplicaSet",
+			wantName: replicaSet.Name,
+		},
+		{
+			name: "should stop at an owner of an unknown kind",
+			pod: pod(namespace, "build", metav1.OwnerReference{
+				APIVersion: "build.openshift.io/v1",
+				Kind:       "Build",
+				Name:       "app-1",
+				Controller: boolPtr(true),
+			}),
+			wantKind: "Build",
+			wantName: "app-1",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := fake.NewSimpleClientset(append(tt.objects, tt.pod)...)
+
+			kind, name, obj, err := resolveTopOwner(context.Background(), client, namespace, tt.pod)
+			if err != nil {
+				t.Fatalf("failed to resolve top owner: %v", err)
+			}
+
+			if kind != tt.wantKind || name != tt.wantName {
+				t.Errorf("expected %s/%s, got %s/%s", tt.wantKind, tt.wantName, kind, name)
+			}
+			if (obj != nil) != tt.wantObj {
+				t.Errorf("expected object %t, got %v", tt.wantObj, obj)
+			}
+		})
+	}
+}
+
+func pod(namespace, name string, owners ...metav1.OwnerReference) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       namespace,
+			OwnerReferences: owners,
+		},
+	}
+}
+
+func controllerRef(kind, name string) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: "apps/v1",
+		Kind:       kind,
+		Name:       name,
+		Controller: boolPtr(true),
+	}
+}
+
+func batchControllerRef(kind, name string) metav1.OwnerReference {
+	ref := controllerRef(kind, name)
+	ref.APIVersion = "batch/v1"
+
+	return ref
+}
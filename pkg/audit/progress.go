@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// progress reports how many namespaces have been processed so far. A nil
+// *progress reports nothing, which is what --quiet asks for.
+type progress struct {
+	out     io.Writer
+	mu      sync.Mutex
+	done    atomic.Int64
+	total   atomic.Int64
+	printed bool
+}
+
+// newProgress returns a progress that reports to out, or nil if quiet.
+func newProgress(out io.Writer, quiet bool) *progress {
+	if quiet {
+		return nil
+	}
+
+	return &progress{out: out}
+}
+
+// setTotal updates the number of namespaces to process, which grows while
+// namespaces are listed page by page.
+func (p *progress) setTotal(total int64) {
+	if p == nil {
+		return
+	}
+
+	p.total.Store(total)
+}
+
+// advance marks another namespace as processed and reports it.
+func (p *progress) advance() {
+	if p == nil {
+		return
+	}
+
+	done := p.done.Add(1)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.out, "\r%d/%d namespaces processed", done, p.total.Load())
+	p.printed = true
+}
+
+// finish ends the progress line, so that later output starts on a line of
+// its own.
+func (p *progress) finish() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.printed {
+		fmt.Fprintln(p.out)
+	}
+}
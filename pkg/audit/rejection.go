@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// enforceVersionLabel pins the version of the enforced level's checks.
+const enforceVersionLabel = "pod-security.kubernetes.io/enforce-version"
+
+// verifyRejections records for the pods violating at the enforce mechanism
+// whether a pod with the same spec would be rejected on create. Existing pods
+// are only warned about when a namespace starts enforcing a level, new ones
+// are rejected unless they are exempt, e.g. by their runtime class. The pods
+// are dry-run created in a scratch namespace per level, so pods that didn't
+// resolve can't be verified and are left unset.
+func verifyRejections(ctx context.Context, log *slog.Logger, client kubernetes.Interface, psViolations []*PSViolation) error {
+	byLevel := map[string][]*PodViolation{}
+	for _, psv := range psViolations {
+		if psv.Mechanism != mechanismEnforce {
+			continue
+		}
+		byLevel[psv.Level] = append(byLevel[psv.Level], psv.PodViolations...)
+	}
+
+	for level, podViolations := range byLevel {
+		if err := verifyRejectionsAtLevel(ctx, log, client, level, podViolations); err != nil {
+			return fmt.Errorf("failed to verify rejections at %s: %w", level, err)
+		}
+	}
+
+	return nil
+}
+
+// verifyRejectionsAtLevel dry-run creates the pods in a scratch namespace
+// enforcing level, e.g. "restricted:latest".
+func verifyRejectionsAtLevel(ctx context.Context, log *slog.Logger, client kubernetes.Interface, level string, podViolations []*PodViolation) error {
+	labels := map[string]string{}
+	labels[enforceLabel], labels[enforceVersionLabel], _ = strings.Cut(level, ":")
+	if labels[enforceVersionLabel] == "" {
+		delete(labels, enforceVersionLabel)
+	}
+
+	namespace, cleanUp, err := createScratchNamespace(ctx, client, labels, "default")
+	if err != nil {
+		return err
+	}
+	defer cleanUp()
+
+	serviceAccounts := map[string]bool{"default": true}
+	for _, podViolation := range podViolations {
+		if podViolation.Pod == nil {
+			log.Debug("Pod not resolved, can't verify its rejection", "pod", podViolation.Name)
+			continue
+		}
+
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: podViolation.Name, Namespace: namespace.Name},
+			Spec:       *podViolation.Pod.Spec.DeepCopy(),
+		}
+		// The ServiceAccount admission rejects pods whose service
+		// account doesn't exist, before PodSecurity gets to see them.
+		if serviceAccount := pod.Spec.ServiceAccountName; serviceAccount != "" && !serviceAccounts[serviceAccount] {
+			_, err := client.CoreV1().ServiceAccounts(namespace.Name).Create(ctx, &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{Name: serviceAccount},
+			}, metav1.CreateOptions{})
+			if err != nil && !apierrors.IsAlreadyExists(err) {
+				return err
+			}
+			serviceAccounts[serviceAccount] = true
+		}
+
+		result, err := dryRunPod(ctx, client, pod)
+		if err != nil {
+			return err
+		}
+		rejected := false
+		for _, psv := range result {
+			if psv.Mechanism == mechanismEnforce {
+				rejected = true
+			}
+		}
+		podViolation.Rejected = &rejected
+	}
+
+	return nil
+}
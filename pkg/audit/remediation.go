@@ -0,0 +1,259 @@
+package audit
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/ibihim/kube-plays/pkg/sccgen"
+)
+
+// jsonPatchOperation is a single RFC 6902 JSON Patch operation.
+type jsonPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// workloadPatch is a JSON Patch that remediates the PodSecurity violations
+// of a single workload. It can be applied with
+// `kubectl patch <kind> <name> -n <namespace> --type json -p '<patch>'`.
+type workloadPatch struct {
+	Cluster   string               `json:"cluster,omitempty"`
+	Namespace string               `json:"namespace"`
+	Kind      string               `json:"kind"`
+	Name      string               `json:"name"`
+	Patch     []jsonPatchOperation `json:"patch"`
+}
+
+// podTemplateSpec returns the pod template spec of the workload and the JSON
+// Patch path to it, or nil if the workload is of a kind without a pod
+// template.
+func podTemplateSpec(workload runtime.Object) (*corev1.PodSpec, string) {
+	switch w := workload.(type) {
+	case *appsv1.Deployment:
+		return &w.Spec.Template.Spec, "/spec/template/spec"
+	case *appsv1.ReplicaSet:
+		return &w.Spec.Template.Spec, "/spec/template/spec"
+	case *appsv1.StatefulSet:
+		return &w.Spec.Template.Spec, "/spec/template/spec"
+	case *appsv1.DaemonSet:
+		return &w.Spec.Template.Spec, "/spec/template/spec"
+	case *batchv1.Job:
+		return &w.Spec.Template.Spec, "/spec/template/spec"
+	case *batchv1.CronJob:
+		return &w.Spec.JobTemplate.Spec.Template.Spec, "/spec/jobTemplate/spec/template/spec"
+	default:
+		return nil, ""
+	}
+}
+
+// remediationPatch returns a JSON Patch that sets the securityContext fields
+// of the pod template spec at prefix to values that comply with the
+// restricted profile. Only the controls listed in the violations are fixed.
+// It returns nil if nothing needs to change.
+func remediationPatch(original *corev1.PodSpec, prefix string, violations []string) []jsonPatchOperation {
+	fixed := original.DeepCopy()
+
+	for _, violation := range violations {
+		remediate(fixed, violation)
+	}
+
+	var patch []jsonPatchOperation
+	if !equality.Semantic.DeepEqual(original.SecurityContext, fixed.SecurityContext) {
+		patch = append(patch, jsonPatchOperation{
+			Op:    "add",
+			Path:  prefix + "/securityContext",
+			Value: fixed.SecurityContext,
+		})
+	}
+
+	for i := range fixed.InitContainers {
+		if !equality.Semantic.DeepEqual(original.InitContainers[i].SecurityContext, fixed.InitContainers[i].SecurityContext) {
+			patch = append(patch, jsonPatchOperation{
+				Op:    "add",
+				Path:  prefix + "/initContainers/" + strconv.Itoa(i) + "/securityContext",
+				Value: fixed.InitContainers[i].SecurityContext,
+			})
+		}
+	}
+
+	for i := range fixed.Containers {
+		if !equality.Semantic.DeepEqual(original.Containers[i].SecurityContext, fixed.Containers[i].SecurityContext) {
+			patch = append(patch, jsonPatchOperation{
+				Op:    "add",
+				Path:  prefix + "/containers/" + strconv.Itoa(i) + "/securityContext",
+				Value: fixed.Containers[i].SecurityContext,
+			})
+		}
+	}
+
+	return patch
+}
+
+// remediate mutates the pod spec so that it no longer violates the given
+// PodSecurity control. Controls that can't be fixed by adjusting a
+// securityContext, like volume types, are left untouched.
+func remediate(spec *corev1.PodSpec, violation string) {
+	switch {
+	case strings.HasPrefix(violation, "privileged"):
+		forEachContainer(spec, func(sc *corev1.SecurityContext) {
+			if sc.Privileged != nil && *sc.Privileged {
+				sc.Privileged = boolPtr(false)
+			}
+		})
+	case strings.HasPrefix(violation, "allowPrivilegeEscalation != false"):
+		forEachContainer(spec, func(sc *corev1.SecurityContext) {
+			sc.AllowPrivilegeEscalation = boolPtr(false)
+		})
+	case strings.HasPrefix(violation, "unrestricted capabilities"):
+		forEachContainer(spec, func(sc *corev1.SecurityContext) {
+			if sc.Capabilities == nil {
+				sc.Capabilities = &corev1.Capabilities{}
+			}
+			sc.Capabilities.Drop = []corev1.Capability{"ALL"}
+
+			// NET_BIND_SERVICE is the only capability restricted allows to add.
+			var add []corev1.Capability
+			for _, capability := range sc.Capabilities.Add {
+				if capability == "NET_BIND_SERVICE" {
+					add = append(add, capability)
+				}
+			}
+			sc.Capabilities.Add = add
+		})
+	case strings.HasPrefix(violation, "runAsNonRoot != true"):
+		podSecurityContext(spec).RunAsNonRoot = boolPtr(true)
+		forEachContainer(spec, func(sc *corev1.SecurityContext) {
+			if sc.RunAsNonRoot != nil && !*sc.RunAsNonRoot {
+				sc.RunAsNonRoot = nil
+			}
+		})
+	case strings.HasPrefix(violation, "runAsUser=0"):
+		if psc := spec.SecurityContext; psc != nil && psc.RunAsUser != nil && *psc.RunAsUser == 0 {
+			psc.RunAsUser = nil
+		}
+		forEachContainer(spec, func(sc *corev1.SecurityContext) {
+			if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+				sc.RunAsUser = nil
+			}
+		})
+	case strings.HasPrefix(violation, "seccompProfile"):
+		podSecurityContext(spec).SeccompProfile = &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		}
+		forEachContainer(spec, func(sc *corev1.SecurityContext) {
+			if sc.SeccompProfile != nil && sc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+				sc.SeccompProfile.Type = corev1.SeccompProfileTypeRuntimeDefault
+			}
+		})
+	}
+}
+
+// forEachContainer calls fn with the securityContext of every container and
+// init container, creating empty securityContexts where necessary.
+func forEachContainer(spec *corev1.PodSpec, fn func(*corev1.SecurityContext)) {
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for i := range containers {
+			if containers[i].SecurityContext == nil {
+				containers[i].SecurityContext = &corev1.SecurityContext{}
+			}
+			fn(containers[i].SecurityContext)
+		}
+	}
+}
+
+// podSecurityContext returns the pod-level securityContext, creating it if
+// necessary.
+func podSecurityContext(spec *corev1.PodSpec) *corev1.PodSecurityContext {
+	if spec.SecurityContext == nil {
+		spec.SecurityContext = &corev1.PodSecurityContext{}
+	}
+
+	return spec.SecurityContext
+}
+
+// remediationWorkload collects the violations of all pods of a workload, at
+// every mechanism, so that a single patch fixes all of them.
+type remediationWorkload struct {
+	cluster    string
+	namespace  string
+	kind       string
+	name       string
+	object     runtime.Object
+	violations []string
+}
+
+// writeRemediationPatches writes a remediation patch for every resolved
+// workload in the violations to path. Workloads of a kind without a pod
+// template, like bare pods, or whose object is gone are logged and skipped.
+func writeRemediationPatches(log *slog.Logger, path string, psViolations []*PSViolation) error {
+	var (
+		workloads []*remediationWorkload
+		byKey     = map[string]*remediationWorkload{}
+	)
+
+	for _, psv := range psViolations {
+		for _, podViolation := range psv.PodViolations {
+			kind, name := podViolation.WorkloadKind, podViolation.WorkloadName
+			if kind == "" {
+				continue
+			}
+
+			key := psv.Cluster + "/" + psv.Namespace + "/" + kind + "/" + name
+			workload, ok := byKey[key]
+			if !ok {
+				workload = &remediationWorkload{
+					cluster:   psv.Cluster,
+					namespace: psv.Namespace,
+					kind:      kind,
+					name:      name,
+					object:    podViolation.WorkloadObject,
+				}
+				byKey[key] = workload
+				workloads = append(workloads, workload)
+			}
+			for _, violation := range podViolation.Violations {
+				workload.violations = sccgen.AppendUnique(workload.violations, violation)
+			}
+		}
+	}
+
+	patches := []workloadPatch{}
+	for _, workload := range workloads {
+		spec, prefix := podTemplateSpec(workload.object)
+		if spec == nil {
+			log.Warn("No remediation patch for workload of unsupported kind", "cluster", workload.cluster, "namespace", workload.namespace, "kind", workload.kind, "name", workload.name)
+			continue
+		}
+		patch := remediationPatch(spec, prefix, workload.violations)
+		if len(patch) == 0 {
+			continue
+		}
+
+		patches = append(patches, workloadPatch{
+			Cluster:   workload.cluster,
+			Namespace: workload.namespace,
+			Kind:      workload.kind,
+			Name:      workload.name,
+			Patch:     patch,
+		})
+	}
+
+	data, err := json.MarshalIndent(patches, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+func boolPtr(b bool) *bool { return &b }
@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestWriteRemediationPatches(t *testing.T) {
+	template := corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+	}
+	violation := func(name, kind string, workload runtime.Object) *PodViolation {
+		return &PodViolation{
+			Name:           name,
+			WorkloadKind:   kind,
+			WorkloadName:   "app",
+			WorkloadObject: workload,
+			Violations:     []string{`allowPrivilegeEscalation != false (container "app" must set securityContext.allowPrivilegeEscalation=false)`},
+		}
+	}
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "app"},
+		Spec:       appsv1.StatefulSetSpec{Template: template},
+	}
+	daemonSet := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "app"},
+		Spec:       appsv1.DaemonSetSpec{Template: template},
+	}
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "app"},
+		Spec: batchv1.CronJobSpec{
+			JobTemplate: batchv1.JobTemplateSpec{Spec: batchv1.JobSpec{Template: template}},
+		},
+	}
+
+	psViolations := []*PSViolation{
+		{
+			Cluster:   "a",
+			Namespace: "p0t-sekurity",
+			PodViolations: []*PodViolation{
+				violation("app-0", "StatefulSet", statefulSet),
+				violation("app-1", "StatefulSet", statefulSet),
+				violation("app-28467360-q8k2m", "CronJob", cronJob),
+				violation("bare", "Pod", &corev1.Pod{}),
+			},
+		},
+		{
+			Cluster:       "b",
+			Namespace:     "p0t-sekurity",
+			PodViolations: []*PodViolation{violation("app-0", "StatefulSet", statefulSet)},
+		},
+		{
+			Cluster:       "c",
+			Namespace:     "p0t-sekurity",
+			Mechanism:     mechanismEnforce,
+			PodViolations: []*PodViolation{violation("app-x2x9z", "DaemonSet", daemonSet)},
+		},
+		{
+			Cluster:   "c",
+			Namespace: "p0t-sekurity",
+			Mechanism: mechanismWarn,
+			PodViolations: []*PodViolation{{
+				Name:           "app-q8k2m",
+				WorkloadKind:   "DaemonSet",
+				WorkloadName:   "app",
+				WorkloadObject: daemonSet,
+				Violations:     []string{`seccompProfile (pod or container "app" must set securityContext.seccompProfile.type to "RuntimeDefault" or "Localhost")`},
+			}},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "patches.json")
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if err := writeRemediationPatches(log, path, psViolations); err != nil {
+		t.Fatalf("failed to write patches: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read patches: %v", err)
+	}
+	var patches []workloadPatch
+	if err := json.Unmarshal(data, &patches); err != nil {
+		t.Fatalf("failed to decode patches: %v", err)
+	}
+
+	want := []struct {
+		cluster, kind string
+		paths         []string
+	}{
+		{"a", "StatefulSet", []string{"/spec/template/spec/containers/0/securityContext"}},
+		{"a", "CronJob", []string{"/spec/jobTemplate/spec/template/spec/containers/0/securityContext"}},
+		{"b", "StatefulSet", []string{"/spec/template/spec/containers/0/securityContext"}},
+		// The enforce and warn violations of the DaemonSet are fixed by a
+		// single patch.
+		{"c", "DaemonSet", []string{"/spec/template/spec/securityContext", "/spec/template/spec/containers/0/securityContext"}},
+	}
+	if len(patches) != len(want) {
+		t.Fatalf("expected %d patches, got %+v", len(want), patches)
+	}
+	for i, w := range want {
+		got := patches[i]
+		var paths []string
+		for _, operation := range got.Patch {
+			paths = append(paths, operation.Path)
+		}
+		if got.Cluster != w.cluster || got.Kind != w.kind || !reflect.DeepEqual(paths, w.paths) {
+			t.Errorf("expected a patch of %s %s at %v, got %+v", w.cluster, w.kind, w.paths, got)
+		}
+	}
+}
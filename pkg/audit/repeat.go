@@ -0,0 +1,35 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// repeatRun calls run every interval until ctx is done. A failing first run
+// stops the repetition, as it most likely fails for good, later failures are
+// logged and retried on the next interval. Runs that are cut short by ctx
+// ending aren't errors.
+func repeatRun(ctx context.Context, log *slog.Logger, interval time.Duration, run func(context.Context) error) error {
+	for i := 1; ; i++ {
+		started := time.Now()
+		log.Info("Starting audit run", "run", i)
+
+		err := run(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case err != nil && i == 1:
+			return err
+		case err != nil:
+			log.Error("Audit run failed", "run", i, "err", err)
+		}
+
+		// Runs that take longer than the interval start right away.
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Until(started.Add(interval))):
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestRepeatRun(t *testing.T) {
+	failure := errors.New("apiserver unreachable")
+
+	for _, tt := range []struct {
+		name     string
+		failRuns map[int]bool
+		wantRuns int
+		wantErr  error
+	}{
+		{
+			name:     "should repeat until interrupted",
+			wantRuns: 3,
+		},
+		{
+			name:     "should stop if the first run fails",
+			failRuns: map[int]bool{1: true},
+			wantRuns: 1,
+			wantErr:  failure,
+		},
+		{
+			name:     "should keep repeating if a later run fails",
+			failRuns: map[int]bool{2: true},
+			wantRuns: 3,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			runs := 0
+			err := repeatRun(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)), time.Millisecond, func(context.Context) error {
+				runs++
+				if runs == 3 {
+					cancel()
+				}
+				if tt.failRuns[runs] {
+					return failure
+				}
+				return nil
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+			if runs != tt.wantRuns {
+				t.Errorf("got %d runs, want %d", runs, tt.wantRuns)
+			}
+		})
+	}
+}
@@ -0,0 +1,474 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/ibihim/kube-plays/pkg/sccgen"
+)
+
+// Output formats of the report.
+const (
+	outputJSON     = "json"
+	outputHTML     = "html"
+	outputMarkdown = "markdown"
+	outputJSONL    = "jsonl"
+	outputTable    = "table"
+	outputYAML     = "yaml"
+)
+
+// Report is the serialized form of the collected violations. Unlike
+// PSViolation it leaves out the resolved pod and workload objects, which are
+// huge and noisy once encoded.
+type Report struct {
+	Summary    ReportSummary     `json:"summary"`
+	Namespaces []NamespaceReport `json:"namespaces"`
+	// NotFound lists the namespaces of --namespaces-file that don't exist.
+	NotFound []string `json:"notFound,omitempty"`
+	// Clean lists the namespaces without violations, unless they are left
+	// out with --only-violations.
+	Clean []string `json:"clean,omitempty"`
+	// AlreadyEnforcing lists the namespaces that enforce their target level
+	// already, which weren't checked against it again.
+	AlreadyEnforcing []string `json:"alreadyEnforcing,omitempty"`
+}
+
+// ReportSummary counts what the report contains.
+type ReportSummary struct {
+	Namespaces int `json:"namespaces"`
+	Workloads  int `json:"workloads"`
+	Pods       int `json:"pods"`
+	Violations int `json:"violations"`
+	// Clean counts the namespaces without violations, whether they are
+	// listed or not.
+	Clean int `json:"clean,omitempty"`
+	// AlreadyEnforcing counts the namespaces that enforce their target
+	// level already.
+	AlreadyEnforcing int `json:"alreadyEnforcing,omitempty"`
+	// Rejected and WarnedOnly count the pods whose specs would and wouldn't
+	// be rejected on create, with --verify-rejection.
+	Rejected   int `json:"rejected,omitempty"`
+	WarnedOnly int `json:"warnedOnly,omitempty"`
+	// AuditDefaulted counts the namespaces without an audit label, which
+	// were checked against restricted.
+	AuditDefaulted int `json:"auditDefaulted,omitempty"`
+	// Kinds counts the violations per workload kind, bare pods count as
+	// "Pod".
+	Kinds map[string]int `json:"kinds,omitempty"`
+	// Owners counts the violations per namespace owner with
+	// --owner-annotation.
+	Owners map[string]int `json:"owners,omitempty"`
+}
+
+// NamespaceReport lists the pods of a namespace that violate the level.
+type NamespaceReport struct {
+	Cluster      string        `json:"cluster,omitempty"`
+	Namespace    string        `json:"namespace"`
+	Level        string        `json:"level"`
+	Mechanism    string        `json:"mechanism,omitempty"`
+	LabelChanges []LabelChange `json:"labelChanges,omitempty"`
+	// AuditDefaulted is set if the namespace has no audit label and was
+	// checked against restricted.
+	AuditDefaulted bool `json:"auditDefaulted,omitempty"`
+	// Owner is the team owning the namespace according to
+	// --owner-annotation.
+	Owner string      `json:"owner,omitempty"`
+	Pods  []PodReport `json:"pods"`
+}
+
+// PodReport lists the violations of a pod and the workload it belongs to.
+type PodReport struct {
+	Name          string   `json:"name"`
+	WorkloadKind  string   `json:"workloadKind,omitempty"`
+	WorkloadName  string   `json:"workloadName,omitempty"`
+	HelmRelease   string   `json:"helmRelease,omitempty"`
+	HelmNamespace string   `json:"helmNamespace,omitempty"`
+	Violations    []string `json:"violations"`
+	// Containers attributes the violations to the containers causing them,
+	// if the pod was resolved.
+	Containers []ContainerReport `json:"containers,omitempty"`
+	// Rejected tells whether a new pod with the same spec would be rejected,
+	// with --verify-rejection.
+	Rejected *bool `json:"rejected,omitempty"`
+
+	// Pod and Workload are the full objects of the pod and its top-most
+	// owner. They are only included with --include-objects, as they make
+	// up most of the report's size.
+	Pod      *corev1.Pod `json:"pod,omitempty"`
+	Workload interface{} `json:"workload,omitempty"`
+}
+
+// newReport trims the violations down to their serializable form. The
+// namespaces, pods and violations are sorted, so that the report of the same
+// cluster is the same regardless of the order the warnings arrived in.
+func newReport(psViolations []*PSViolation) *Report {
+	report := &Report{Namespaces: []NamespaceReport{}}
+
+	for _, psv := range psViolations {
+		report.Namespaces = append(report.Namespaces, newNamespaceReport(psv))
+	}
+
+	sort.SliceStable(report.Namespaces, func(i, j int) bool {
+		a, b := report.Namespaces[i], report.Namespaces[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Mechanism < b.Mechanism
+	})
+
+	report.Summary = summarize(report.Namespaces)
+
+	return report
+}
+
+// addClean counts the namespaces of the cluster that have no violations and,
+// if list is set, lists them sorted by name.
+func (r *Report) addClean(cluster string, namespaces []*corev1.Namespace, list bool) {
+	r.Summary.Clean += len(namespaces)
+	if !list {
+		return
+	}
+
+	for _, namespace := range namespaces {
+		name := namespace.Name
+		if cluster != "" {
+			name = cluster + "/" + name
+		}
+		r.Clean = append(r.Clean, name)
+	}
+	sort.Strings(r.Clean)
+}
+
+// addAlreadyEnforcing counts and lists the namespaces of the cluster that
+// enforce their target level already, sorted by name.
+func (r *Report) addAlreadyEnforcing(cluster string, names []string) {
+	r.Summary.AlreadyEnforcing += len(names)
+	for _, name := range names {
+		if cluster != "" {
+			name = cluster + "/" + name
+		}
+		r.AlreadyEnforcing = append(r.AlreadyEnforcing, name)
+	}
+	sort.Strings(r.AlreadyEnforcing)
+}
+
+// includeObjects adds the full pod and workload objects of the violations to
+// the pods of the report.
+func (r *Report) includeObjects(psViolations []*PSViolation) {
+	podViolations := map[string]*PodViolation{}
+	for _, psv := range psViolations {
+		for _, podViolation := range psv.PodViolations {
+			podViolations[psv.Cluster+"/"+psv.Namespace+"/"+psv.Mechanism+"/"+podViolation.Name] = podViolation
+		}
+	}
+
+	for _, nsReport := range r.Namespaces {
+		for i := range nsReport.Pods {
+			podViolation, ok := podViolations[nsReport.Cluster+"/"+nsReport.Namespace+"/"+nsReport.Mechanism+"/"+nsReport.Pods[i].Name]
+			if !ok {
+				continue
+			}
+
+			nsReport.Pods[i].Pod = podViolation.Pod
+			if podViolation.WorkloadObject != nil {
+				nsReport.Pods[i].Workload = podViolation.WorkloadObject
+			}
+		}
+	}
+}
+
+// newNamespaceReport trims the violations of a namespace down to their
+// serializable form, with the pods sorted by name and their violations
+// alphabetically.
+func newNamespaceReport(psv *PSViolation) NamespaceReport {
+	nsReport := NamespaceReport{
+		Cluster:        psv.Cluster,
+		Namespace:      psv.Namespace,
+		Level:          psv.Level,
+		Mechanism:      psv.Mechanism,
+		LabelChanges:   psv.LabelChanges,
+		AuditDefaulted: psv.AuditDefaulted,
+		Owner:          psv.Owner,
+		Pods:           []PodReport{},
+	}
+
+	for _, podViolation := range psv.PodViolations {
+		kind, name := podViolation.workload()
+
+		violations := append([]string{}, podViolation.Violations...)
+		sort.Strings(violations)
+
+		var containers []ContainerReport
+		if podViolation.Pod != nil {
+			containers = containerBreakdown(&podViolation.Pod.Spec, violations)
+		}
+
+		nsReport.Pods = append(nsReport.Pods, PodReport{
+			Name:          podViolation.Name,
+			WorkloadKind:  kind,
+			WorkloadName:  name,
+			HelmRelease:   podViolation.HelmRelease,
+			HelmNamespace: podViolation.HelmNamespace,
+			Violations:    violations,
+			Containers:    containers,
+			Rejected:      podViolation.Rejected,
+		})
+	}
+
+	sort.SliceStable(nsReport.Pods, func(i, j int) bool {
+		return nsReport.Pods[i].Name < nsReport.Pods[j].Name
+	})
+
+	return nsReport
+}
+
+// WorkloadLine is a single line of the jsonl output, holding the violations
+// of one workload.
+type WorkloadLine struct {
+	Cluster       string   `json:"cluster,omitempty"`
+	Namespace     string   `json:"namespace"`
+	Level         string   `json:"level"`
+	Mechanism     string   `json:"mechanism,omitempty"`
+	Owner         string   `json:"owner,omitempty"`
+	Kind          string   `json:"kind"`
+	Name          string   `json:"name"`
+	HelmRelease   string   `json:"helmRelease,omitempty"`
+	HelmNamespace string   `json:"helmNamespace,omitempty"`
+	Pods          []string `json:"pods"`
+	Violations    []string `json:"violations"`
+}
+
+// writeWorkloadLines encodes a line per violating workload of the namespace.
+func writeWorkloadLines(enc *json.Encoder, nsReport NamespaceReport) error {
+	for _, workload := range nsReport.Workloads() {
+		line := WorkloadLine{
+			Cluster:       nsReport.Cluster,
+			Namespace:     nsReport.Namespace,
+			Level:         nsReport.Level,
+			Mechanism:     nsReport.Mechanism,
+			Owner:         nsReport.Owner,
+			Kind:          workload.Kind,
+			Name:          workload.Name,
+			HelmRelease:   workload.HelmRelease(),
+			HelmNamespace: workload.HelmNamespace(),
+			Violations:    workload.Violations(),
+		}
+		for _, podReport := range workload.Pods {
+			line.Pods = append(line.Pods, podReport.Name)
+		}
+
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// summarize counts the namespaces, workloads, pods and violations. Pods that
+// don't belong to a workload count as their own workload.
+func summarize(namespaces []NamespaceReport) ReportSummary {
+	summary := ReportSummary{}
+	seen := map[string]bool{}
+
+	for _, nsReport := range namespaces {
+		namespaceKey := nsReport.Cluster + "/" + nsReport.Namespace
+		if !seen[namespaceKey] {
+			seen[namespaceKey] = true
+			summary.Namespaces++
+			if nsReport.AuditDefaulted {
+				summary.AuditDefaulted++
+			}
+		}
+
+		for _, workload := range nsReport.Workloads() {
+			key := fmt.Sprintf("%s/%s/%s", namespaceKey, workload.Kind, workload.Name)
+			if !seen[key] {
+				seen[key] = true
+				summary.Workloads++
+			}
+		}
+
+		for _, podReport := range nsReport.Pods {
+			summary.Pods++
+			switch {
+			case podReport.Rejected == nil:
+			case *podReport.Rejected:
+				summary.Rejected++
+			default:
+				summary.WarnedOnly++
+			}
+			summary.Violations += len(podReport.Violations)
+
+			if len(podReport.Violations) > 0 {
+				kind := podReport.WorkloadKind
+				if kind == "" {
+					kind = "Pod"
+				}
+				if summary.Kinds == nil {
+					summary.Kinds = map[string]int{}
+				}
+				summary.Kinds[kind] += len(podReport.Violations)
+
+				if nsReport.Owner != "" {
+					if summary.Owners == nil {
+						summary.Owners = map[string]int{}
+					}
+					summary.Owners[nsReport.Owner] += len(podReport.Violations)
+				}
+			}
+		}
+	}
+
+	return summary
+}
+
+// WorkloadReport groups the violating pods of a workload.
+type WorkloadReport struct {
+	Kind string
+	Name string
+	Pods []PodReport
+}
+
+// Workloads groups the pods of the namespace by their workload, in the order
+// the workloads first appear. Pods without a workload are their own group.
+func (n NamespaceReport) Workloads() []WorkloadReport {
+	var workloads []WorkloadReport
+	index := map[string]int{}
+
+	for _, podReport := range n.Pods {
+		kind, name := podReport.WorkloadKind, podReport.WorkloadName
+		if kind == "" {
+			kind, name = "Pod", podReport.Name
+		}
+
+		key := kind + "/" + name
+		i, ok := index[key]
+		if !ok {
+			i = len(workloads)
+			index[key] = i
+			workloads = append(workloads, WorkloadReport{Kind: kind, Name: name})
+		}
+		workloads[i].Pods = append(workloads[i].Pods, podReport)
+	}
+
+	return workloads
+}
+
+// validateOutput fails if the output format isn't supported, so that a typo
+// doesn't surface only after the whole audit ran.
+func validateOutput(output string) error {
+	switch output {
+	case outputJSON, outputHTML, outputMarkdown, outputJSONL, outputTable, outputYAML:
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q", output)
+	}
+}
+
+// Violations returns the distinct violations of the workload's pods.
+func (w WorkloadReport) Violations() []string {
+	var violations []string
+	seen := map[string]bool{}
+
+	for _, podReport := range w.Pods {
+		for _, violation := range podReport.Violations {
+			if !seen[violation] {
+				seen[violation] = true
+				violations = append(violations, violation)
+			}
+		}
+	}
+
+	return violations
+}
+
+// HelmRelease returns the Helm release that manages the workload, if any.
+func (w WorkloadReport) HelmRelease() string {
+	if len(w.Pods) == 0 {
+		return ""
+	}
+
+	return w.Pods[0].HelmRelease
+}
+
+// HelmNamespace returns the namespace of the workload's Helm release.
+func (w WorkloadReport) HelmNamespace() string {
+	if len(w.Pods) == 0 {
+		return ""
+	}
+
+	return w.Pods[0].HelmNamespace
+}
+
+// Remediations returns the distinct remediations of the workload's
+// violations.
+func (w WorkloadReport) Remediations() []string {
+	var remediations []string
+	for _, violation := range w.Violations() {
+		remediations = sccgen.AppendUnique(remediations, ParseViolation(violation).Remediation())
+	}
+
+	return remediations
+}
+
+// writeReport writes the report in the given output format. Color only
+// applies to the table.
+func writeReport(w io.Writer, report *Report, output string, color bool) error {
+	switch output {
+	case outputJSON:
+		return json.NewEncoder(w).Encode(report)
+	case outputHTML:
+		return htmlReport.Execute(w, report)
+	case outputMarkdown:
+		return markdownReport.Execute(w, report)
+	case outputJSONL:
+		enc := json.NewEncoder(w)
+		for _, nsReport := range report.Namespaces {
+			if err := writeWorkloadLines(enc, nsReport); err != nil {
+				return err
+			}
+		}
+		return nil
+	case outputTable:
+		return writeTable(w, report, color)
+	case outputYAML:
+		data, err := yaml.Marshal(report)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	default:
+		return fmt.Errorf("unknown output format %q", output)
+	}
+}
+
+// workload returns the kind and name of the workload the pod belongs to. If
+// the workload hasn't been resolved, it falls back to the pod's controller.
+func (p *PodViolation) workload() (string, string) {
+	if p.WorkloadKind != "" {
+		return p.WorkloadKind, p.WorkloadName
+	}
+
+	if p.Deployment != nil {
+		return "Deployment", p.Deployment.Name
+	}
+
+	if p.Pod != nil {
+		if owner := metav1.GetControllerOf(p.Pod); owner != nil {
+			return owner.Kind, owner.Name
+		}
+	}
+
+	return "", ""
+}
@@ -0,0 +1,242 @@
+package audit
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReportIsSorted(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		psViolations []*PSViolation
+	}{
+		{
+			name: "should sort shuffled namespaces, pods and violations",
+			psViolations: []*PSViolation{
+				{
+					Namespace: "zeta",
+					Level:     "restricted",
+					PodViolations: []*PodViolation{
+						{Name: "web-2", Violations: []string{"seccompProfile", "allowPrivilegeEscalation != false"}},
+						{Name: "web-1", Violations: []string{"runAsNonRoot != true", "privileged"}},
+					},
+				},
+				{
+					Namespace: "alpha",
+					Level:     "baseline",
+					PodViolations: []*PodViolation{
+						{Name: "db-0", Violations: []string{"hostPath volumes", "host namespaces"}},
+					},
+				},
+			},
+		},
+		{
+			name: "should sort the same violations in a different order identically",
+			psViolations: []*PSViolation{
+				{
+					Namespace: "alpha",
+					Level:     "baseline",
+					PodViolations: []*PodViolation{
+						{Name: "db-0", Violations: []string{"host namespaces", "hostPath volumes"}},
+					},
+				},
+				{
+					Namespace: "zeta",
+					Level:     "restricted",
+					PodViolations: []*PodViolation{
+						{Name: "web-1", Violations: []string{"privileged", "runAsNonRoot != true"}},
+						{Name: "web-2", Violations: []string{"allowPrivilegeEscalation != false", "seccompProfile"}},
+					},
+				},
+			},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			const want = `{"summary":{"namespaces":2,"workloads":3,"pods":3,"violations":6,"kinds":{"Pod":6}},"namespaces":[` +
+				`{"namespace":"alpha","level":"baseline","pods":[{"name":"db-0","violations":["host namespaces","hostPath volumes"]}]},` +
+				`{"namespace":"zeta","level":"restricted","pods":[` +
+				`{"name":"web-1","violations":["privileged","runAsNonRoot != true"]},` +
+				`{"name":"web-2","violations":["allowPrivilegeEscalation != false","seccompProfile"]}]}]}` + "\n"
+
+			var buf bytes.Buffer
+			if err := writeReport(&buf, newReport(tt.psViolations), outputJSON, false); err != nil {
+				t.Fatalf("failed to write report: %v", err)
+			}
+
+			if got := buf.String(); got != want {
+				t.Errorf("unexpected report:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestSummaryCountsViolationsPerKind(t *testing.T) {
+	report := newReport([]*PSViolation{
+		{
+			Namespace: "apps",
+			Level:     "restricted",
+			PodViolations: []*PodViolation{
+				{Name: "web-abc-1", WorkloadKind: "Deployment", WorkloadName: "web", Violations: []string{"privileged", "seccompProfile"}},
+				{Name: "web-abc-2", WorkloadKind: "Deployment", WorkloadName: "web", Violations: []string{"privileged"}},
+				{Name: "db-0", WorkloadKind: "StatefulSet", WorkloadName: "db", Violations: []string{"runAsNonRoot != true"}},
+				{Name: "debug", Violations: []string{"host namespaces"}},
+			},
+		},
+		{
+			Namespace: "infra",
+			Level:     "baseline",
+			PodViolations: []*PodViolation{
+				{Name: "agent-x", WorkloadKind: "DaemonSet", WorkloadName: "agent", Violations: []string{"hostPath volumes", "host namespaces", "privileged"}},
+				{Name: "backup-28-abc", WorkloadKind: "CronJob", WorkloadName: "backup", Violations: []string{"hostPath volumes"}},
+				{Name: "migrate-abc", WorkloadKind: "Job", WorkloadName: "migrate", Violations: []string{"seccompProfile"}},
+			},
+		},
+	})
+
+	want := map[string]int{
+		"CronJob":     1,
+		"DaemonSet":   3,
+		"Deployment":  3,
+		"Job":         1,
+		"Pod":         1,
+		"StatefulSet": 1,
+	}
+	if !reflect.DeepEqual(report.Summary.Kinds, want) {
+		t.Errorf("got %v, want %v", report.Summary.Kinds, want)
+	}
+
+	var out bytes.Buffer
+	if err := writeTable(&out, report, false); err != nil {
+		t.Fatalf("failed to write table: %v", err)
+	}
+	if line := "Violations by kind: DaemonSet 3, Deployment 3, CronJob 1, Job 1, Pod 1, StatefulSet 1\n"; !strings.Contains(out.String(), line) {
+		t.Errorf("table doesn't contain %q:\n%s", line, out.String())
+	}
+}
+
+func TestReportAddClean(t *testing.T) {
+	clean := []*corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "quiet"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "idle"}},
+	}
+
+	for _, tt := range []struct {
+		name      string
+		list      bool
+		wantClean []string
+		wantLine  string
+	}{
+		{
+			name:     "should only count clean namespaces with --only-violations",
+			wantLine: "2 namespaces without violations\n",
+		},
+		{
+			name:      "should list clean namespaces without --only-violations",
+			list:      true,
+			wantClean: []string{"prod/idle", "prod/quiet"},
+			wantLine:  "Clean: prod/idle, prod/quiet\n",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			report := newReport(nil)
+			report.addClean("prod", clean, tt.list)
+
+			if report.Summary.Clean != len(clean) {
+				t.Errorf("got %d clean namespaces in the summary, want %d", report.Summary.Clean, len(clean))
+			}
+			if !reflect.DeepEqual(report.Clean, tt.wantClean) {
+				t.Errorf("got clean %v, want %v", report.Clean, tt.wantClean)
+			}
+
+			var out bytes.Buffer
+			if err := writeTable(&out, report, false); err != nil {
+				t.Fatalf("failed to write table: %v", err)
+			}
+			if !strings.Contains(out.String(), tt.wantLine) {
+				t.Errorf("table doesn't contain %q:\n%s", tt.wantLine, out.String())
+			}
+		})
+	}
+}
+
+func TestSummaryCountsViolationsPerOwner(t *testing.T) {
+	report := newReport([]*PSViolation{
+		{
+			Namespace: "payments",
+			Level:     "restricted",
+			Owner:     "team-payments",
+			PodViolations: []*PodViolation{
+				{Name: "api-1", Violations: []string{"privileged", "seccompProfile"}},
+			},
+		},
+		{
+			Namespace: "checkout",
+			Level:     "restricted",
+			Owner:     "team-payments",
+			PodViolations: []*PodViolation{
+				{Name: "web-1", Violations: []string{"runAsNonRoot != true"}},
+			},
+		},
+		{
+			Namespace: "legacy",
+			Level:     "baseline",
+			Owner:     unassignedOwner,
+			PodViolations: []*PodViolation{
+				{Name: "cron-1", Violations: []string{"hostPath volumes"}},
+			},
+		},
+	})
+
+	want := map[string]int{"team-payments": 3, unassignedOwner: 1}
+	if !reflect.DeepEqual(report.Summary.Owners, want) {
+		t.Errorf("got %v, want %v", report.Summary.Owners, want)
+	}
+	if owner := report.Namespaces[0].Owner; owner != "team-payments" {
+		t.Errorf("got owner %q for %s, want team-payments", owner, report.Namespaces[0].Namespace)
+	}
+
+	var out bytes.Buffer
+	if err := writeTable(&out, report, false); err != nil {
+		t.Fatalf("failed to write table: %v", err)
+	}
+	if line := "Violations by owner: team-payments 3, unassigned 1\n"; !strings.Contains(out.String(), line) {
+		t.Errorf("table doesn't contain %q:\n%s", line, out.String())
+	}
+}
+
+func TestSummaryCountsRejectedPods(t *testing.T) {
+	rejected, warnedOnly := true, false
+	report := newReport([]*PSViolation{{
+		Namespace: "apps",
+		Level:     "restricted",
+		Mechanism: mechanismEnforce,
+		PodViolations: []*PodViolation{
+			{Name: "privileged", Violations: []string{"privileged"}, Rejected: &rejected},
+			{Name: "kata", Violations: []string{"privileged"}, Rejected: &warnedOnly},
+			{Name: "unresolved", Violations: []string{"privileged"}},
+		},
+	}})
+
+	if report.Summary.Rejected != 1 || report.Summary.WarnedOnly != 1 {
+		t.Errorf("got %d rejected and %d warned only, want 1 and 1", report.Summary.Rejected, report.Summary.WarnedOnly)
+	}
+
+	var out bytes.Buffer
+	if err := writeTable(&out, report, false); err != nil {
+		t.Fatalf("failed to write table: %v", err)
+	}
+	if line := "1 pods would be rejected on create, 1 only warned about\n"; !strings.Contains(out.String(), line) {
+		t.Errorf("table doesn't contain %q:\n%s", line, out.String())
+	}
+}
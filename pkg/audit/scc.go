@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"strings"
+
+	"github.com/ibihim/kube-plays/pkg/sccgen"
+)
+
+// sccWorkload collects the violations of all pods of a workload.
+type sccWorkload struct {
+	cluster        string
+	namespace      string
+	kind           string
+	name           string
+	serviceAccount string
+	controls       []string
+	capabilities   []string
+}
+
+// addedCapabilities returns the capabilities named by a "non-default
+// capabilities" violation, e.g. `container "app" must not include
+// "NET_ADMIN", "SYS_TIME" in securityContext.capabilities.add`.
+func addedCapabilities(violation Violation) []string {
+	_, names, found := strings.Cut(violation.Details, "must not include ")
+	if !found {
+		return nil
+	}
+	names, _, _ = strings.Cut(names, " in ")
+
+	var capabilities []string
+	for _, match := range quotedRegexp.FindAllStringSubmatch(names, -1) {
+		capabilities = append(capabilities, match[1])
+	}
+
+	return capabilities
+}
+
+// sccRecommendations returns a candidate SCC for every workload in the
+// violations, in the order the workloads were found.
+func sccRecommendations(psViolations []*PSViolation) []*sccgen.Recommendation {
+	var (
+		workloads []*sccWorkload
+		byKey     = map[string]*sccWorkload{}
+	)
+
+	for _, psv := range psViolations {
+		for _, podViolation := range psv.PodViolations {
+			kind, name := podViolation.workload()
+			if name == "" {
+				kind, name = "Pod", podViolation.Name
+			}
+
+			// Workloads of different kinds or clusters may share a name.
+			key := psv.Cluster + "/" + psv.Namespace + "/" + kind + "/" + name
+			workload, ok := byKey[key]
+			if !ok {
+				workload = &sccWorkload{
+					cluster:        psv.Cluster,
+					namespace:      psv.Namespace,
+					kind:           kind,
+					name:           name,
+					serviceAccount: "default",
+				}
+				if podViolation.Pod != nil && podViolation.Pod.Spec.ServiceAccountName != "" {
+					workload.serviceAccount = podViolation.Pod.Spec.ServiceAccountName
+				}
+				byKey[key] = workload
+				workloads = append(workloads, workload)
+			}
+
+			for _, text := range podViolation.Violations {
+				violation := ParseViolation(text)
+				workload.controls = append(workload.controls, violation.Control)
+				if violation.Control == "non-default capabilities" {
+					workload.capabilities = append(workload.capabilities, addedCapabilities(violation)...)
+				}
+			}
+		}
+	}
+
+	recommendations := make([]*sccgen.Recommendation, 0, len(workloads))
+	for _, workload := range workloads {
+		recommendation := sccgen.Recommend(
+			workload.name,
+			workload.namespace,
+			workload.serviceAccount,
+			workload.controls,
+			workload.capabilities,
+		)
+		recommendation.Kind, recommendation.Cluster = workload.kind, workload.cluster
+		recommendations = append(recommendations, recommendation)
+	}
+
+	return recommendations
+}
+
+// writeSCCRecommendations writes a candidate SCC for every workload in the
+// violations to path, as a multi-document YAML file.
+func writeSCCRecommendations(path string, psViolations []*PSViolation) error {
+	var buf bytes.Buffer
+	for i, recommendation := range sccRecommendations(psViolations) {
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+
+		data, err := recommendation.YAML()
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
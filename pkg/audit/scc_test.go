@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSCCRecommendationsNames(t *testing.T) {
+	violations := []string{`privileged (container "app" must not set securityContext.privileged=true)`}
+	psViolations := []*PSViolation{{
+		Cluster:   "a",
+		Namespace: "p0t-sekurity",
+		PodViolations: []*PodViolation{
+			{Name: "app-7d4b9c6f5-x2x9z", WorkloadKind: "Deployment", WorkloadName: "app", Violations: violations},
+			{Name: "app-0", WorkloadKind: "StatefulSet", WorkloadName: "app", Violations: violations},
+		},
+	}}
+
+	recommendations := sccRecommendations(psViolations)
+	if len(recommendations) != 2 {
+		t.Fatalf("expected a recommendation per workload, got %d", len(recommendations))
+	}
+
+	names := map[string]bool{}
+	for i, want := range []string{
+		"# a/p0t-sekurity/Deployment/app: relaxes",
+		"# a/p0t-sekurity/StatefulSet/app: relaxes",
+	} {
+		data, err := recommendations[i].YAML()
+		if err != nil {
+			t.Fatalf("failed to render recommendation: %v", err)
+		}
+		if !strings.HasPrefix(string(data), want) {
+			t.Errorf("expected the header %q:\n%s", want, data)
+		}
+
+		_, rest, _ := strings.Cut(string(data), "metadata:\n  name: ")
+		name, _, _ := strings.Cut(rest, "\n")
+		if names[name] {
+			t.Errorf("expected distinct SCC names, got %s twice", name)
+		}
+		names[name] = true
+	}
+	for _, want := range []string{"p0t-sekurity-deployment-app", "p0t-sekurity-statefulset-app"} {
+		if !names[want] {
+			t.Errorf("expected an SCC named %s, got %v", want, names)
+		}
+	}
+}
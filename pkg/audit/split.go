@@ -0,0 +1,93 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// splitExtensions are the file extensions of the per-namespace reports of
+// --split-output-dir by output format.
+var splitExtensions = map[string]string{
+	outputJSON:     ".json",
+	outputYAML:     ".yaml",
+	outputTable:    ".txt",
+	outputMarkdown: ".md",
+	outputHTML:     ".html",
+}
+
+// scannedNamespace is a namespace the audit checked, named
+// "cluster/namespace" if the audit covered several clusters.
+type scannedNamespace struct {
+	cluster   string
+	namespace string
+}
+
+func (n scannedNamespace) String() string {
+	if n.cluster != "" {
+		return n.cluster + "/" + n.namespace
+	}
+
+	return n.namespace
+}
+
+// splitReport splits the report into a report per namespace. The clean and
+// enforcing namespaces get a report without violations, so that they can be
+// told apart from namespaces that weren't audited at all.
+func splitReport(report *Report, clean, enforcing []scannedNamespace) map[scannedNamespace]*Report {
+	reports := map[scannedNamespace]*Report{}
+	get := func(key scannedNamespace) *Report {
+		if reports[key] == nil {
+			reports[key] = &Report{Namespaces: []NamespaceReport{}}
+		}
+		return reports[key]
+	}
+
+	for _, nsReport := range report.Namespaces {
+		split := get(scannedNamespace{cluster: nsReport.Cluster, namespace: nsReport.Namespace})
+		split.Namespaces = append(split.Namespaces, nsReport)
+	}
+	for _, split := range reports {
+		split.Summary = summarize(split.Namespaces)
+	}
+
+	for _, key := range clean {
+		split := get(key)
+		split.Clean = []string{key.String()}
+		split.Summary.Clean = 1
+	}
+	for _, key := range enforcing {
+		split := get(key)
+		split.AlreadyEnforcing = []string{key.String()}
+		split.Summary.AlreadyEnforcing = 1
+	}
+
+	return reports
+}
+
+// writeSplitReports writes each report into dir, named after its namespace
+// and, if set, into a directory per cluster.
+func writeSplitReports(dir string, reports map[scannedNamespace]*Report, output string) error {
+	for key, report := range reports {
+		path := dir
+		if key.cluster != "" {
+			// Context names may contain slashes, which would nest the
+			// directories.
+			path = filepath.Join(path, strings.ReplaceAll(key.cluster, "/", "_"))
+		}
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		if err := writeReport(&buf, report, output, false); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(path, key.namespace+splitExtensions[output]), buf.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteSplitReports(t *testing.T) {
+	report := newReport([]*PSViolation{
+		{
+			Namespace:     "apps",
+			Level:         "restricted",
+			Mechanism:     mechanismEnforce,
+			PodViolations: []*PodViolation{{Name: "web-1", Violations: []string{"privileged"}}},
+		},
+		{
+			Namespace:     "apps",
+			Level:         "restricted",
+			Mechanism:     mechanismWarn,
+			PodViolations: []*PodViolation{{Name: "web-1", Violations: []string{"privileged"}}},
+		},
+	})
+	clean := []scannedNamespace{{namespace: "quiet"}}
+	enforcing := []scannedNamespace{{namespace: "strict"}}
+
+	dir := t.TempDir()
+	if err := writeSplitReports(dir, splitReport(report, clean, enforcing), outputJSON); err != nil {
+		t.Fatalf("failed to write reports: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name           string
+		file           string
+		wantNamespaces int
+		wantClean      []string
+		wantEnforcing  []string
+	}{
+		{
+			name:           "should write the violations of both mechanisms into the namespace's file",
+			file:           "apps.json",
+			wantNamespaces: 2,
+		},
+		{
+			name:      "should write a file without violations for a clean namespace",
+			file:      "quiet.json",
+			wantClean: []string{"quiet"},
+		},
+		{
+			name:          "should write a file without violations for a namespace enforcing already",
+			file:          "strict.json",
+			wantEnforcing: []string{"strict"},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := os.ReadFile(filepath.Join(dir, tt.file))
+			if err != nil {
+				t.Fatalf("failed to read report: %v", err)
+			}
+			var got Report
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("failed to decode report: %v", err)
+			}
+
+			if len(got.Namespaces) != tt.wantNamespaces {
+				t.Errorf("got %d namespace entries, want %d", len(got.Namespaces), tt.wantNamespaces)
+			}
+			if !reflect.DeepEqual(got.Clean, tt.wantClean) {
+				t.Errorf("got clean %v, want %v", got.Clean, tt.wantClean)
+			}
+			if !reflect.DeepEqual(got.AlreadyEnforcing, tt.wantEnforcing) {
+				t.Errorf("got already enforcing %v, want %v", got.AlreadyEnforcing, tt.wantEnforcing)
+			}
+		})
+	}
+}
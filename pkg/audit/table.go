@@ -0,0 +1,180 @@
+package audit
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/term"
+
+	"github.com/ibihim/kube-plays/pkg/sccgen"
+)
+
+// ANSI escape codes of the colored table. All colors have the same length,
+// so that colored columns still line up in the tabwriter.
+const (
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorReset  = "\x1b[0m"
+)
+
+// useColor reports whether the table written to out should be colored: only
+// when out is a terminal, and neither --no-color nor NO_COLOR ask otherwise.
+func useColor(out *os.File, noColor bool) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	return term.IsTerminal(int(out.Fd()))
+}
+
+// colorizer wraps text in ANSI colors, or leaves it alone if disabled.
+type colorizer bool
+
+func (c colorizer) paint(color, text string) string {
+	if !c {
+		return text
+	}
+
+	return color + text + colorReset
+}
+
+// count colors a number of violations, red if there are any.
+func (c colorizer) count(n int) string {
+	if n > 0 {
+		return c.paint(colorRed, strconv.Itoa(n))
+	}
+
+	return c.paint(colorGreen, strconv.Itoa(n))
+}
+
+// level colors a PodSecurity level by how much it allows.
+func (c colorizer) level(level string) string {
+	switch level {
+	case "privileged":
+		return c.paint(colorRed, level)
+	case "baseline":
+		return c.paint(colorYellow, level)
+	default:
+		return c.paint(colorGreen, level)
+	}
+}
+
+// writeTable writes a row per violating workload, followed by the summary.
+func writeTable(w io.Writer, report *Report, color bool) error {
+	c := colorizer(color)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "NAMESPACE\tLEVEL\tWORKLOAD\tPODS\tVIOLATIONS\tCONTROLS\tREMEDIATION")
+	for _, nsReport := range report.Namespaces {
+		namespace := nsReport.Namespace
+		if nsReport.Cluster != "" {
+			namespace = nsReport.Cluster + "/" + namespace
+		}
+
+		for _, workload := range nsReport.Workloads() {
+			violations := workload.Violations()
+
+			var controls []string
+			for _, violation := range violations {
+				controls = sccgen.AppendUnique(controls, ParseViolation(violation).Control)
+			}
+
+			fmt.Fprintf(tw, "%s\t%s\t%s/%s\t%d\t%s\t%s\t%s\n",
+				namespace,
+				c.level(nsReport.Level),
+				workload.Kind, workload.Name,
+				len(workload.Pods),
+				c.count(len(violations)),
+				strings.Join(controls, ", "),
+				strings.Join(workload.Remediations(), "; "),
+			)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	summary := report.Summary
+	_, err := fmt.Fprintf(w, "\n%d namespaces, %d workloads, %d pods, %s violations\n",
+		summary.Namespaces, summary.Workloads, summary.Pods, c.count(summary.Violations))
+	if err != nil {
+		return err
+	}
+
+	if len(summary.Kinds) > 0 {
+		if _, err := fmt.Fprintf(w, "Violations by kind: %s\n", kindBreakdown(summary.Kinds)); err != nil {
+			return err
+		}
+	}
+
+	if summary.Rejected > 0 || summary.WarnedOnly > 0 {
+		_, err := fmt.Fprintf(w, "%s pods would be rejected on create, %d only warned about\n",
+			c.paint(colorRed, strconv.Itoa(summary.Rejected)), summary.WarnedOnly)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(summary.Owners) > 0 {
+		if _, err := fmt.Fprintf(w, "Violations by owner: %s\n", kindBreakdown(summary.Owners)); err != nil {
+			return err
+		}
+	}
+
+	if len(report.NotFound) > 0 {
+		if _, err := fmt.Fprintf(w, "Not found: %s\n", c.paint(colorYellow, strings.Join(report.NotFound, ", "))); err != nil {
+			return err
+		}
+	}
+
+	if len(report.Clean) > 0 {
+		if _, err := fmt.Fprintf(w, "Clean: %s\n", c.paint(colorGreen, strings.Join(report.Clean, ", "))); err != nil {
+			return err
+		}
+	} else if summary.Clean > 0 {
+		if _, err := fmt.Fprintf(w, "%s namespaces without violations\n", c.paint(colorGreen, strconv.Itoa(summary.Clean))); err != nil {
+			return err
+		}
+	}
+
+	if len(report.AlreadyEnforcing) > 0 {
+		if _, err := fmt.Fprintf(w, "Already enforcing: %s\n", c.paint(colorGreen, strings.Join(report.AlreadyEnforcing, ", "))); err != nil {
+			return err
+		}
+	}
+
+	if summary.AuditDefaulted > 0 {
+		_, err = fmt.Fprintf(w, "%s namespaces have no audit label and were checked against %s\n",
+			c.paint(colorYellow, strconv.Itoa(summary.AuditDefaulted)), defaultTargetLevel)
+	}
+
+	return err
+}
+
+// kindBreakdown lists the violations per workload kind, or per owner, most
+// violations first, e.g. "DaemonSet 4, Deployment 2".
+func kindBreakdown(kinds map[string]int) string {
+	names := make([]string, 0, len(kinds))
+	for kind := range kinds {
+		names = append(names, kind)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if kinds[names[i]] != kinds[names[j]] {
+			return kinds[names[i]] > kinds[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	breakdown := make([]string, 0, len(names))
+	for _, kind := range names {
+		breakdown = append(breakdown, fmt.Sprintf("%s %d", kind, kinds[kind]))
+	}
+
+	return strings.Join(breakdown, ", ")
+}
@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// ControlCount counts how often a control is violated across the report.
+type ControlCount struct {
+	Control    string `json:"control"`
+	Violations int    `json:"violations"`
+	// Workloads is the number of distinct workloads violating the control.
+	Workloads int `json:"workloads"`
+	// Share is the fraction of all violations of the report that are of
+	// this control.
+	Share float64 `json:"share"`
+}
+
+// topControls returns the n most violated controls of the report, most
+// violated first. With n < 1 all controls are returned.
+func topControls(report *Report, n int) []ControlCount {
+	var (
+		counts    []ControlCount
+		index     = map[string]int{}
+		workloads = map[string]bool{}
+	)
+
+	for _, nsReport := range report.Namespaces {
+		for _, workload := range nsReport.Workloads() {
+			workloadKey := fmt.Sprintf("%s/%s/%s/%s", nsReport.Cluster, nsReport.Namespace, workload.Kind, workload.Name)
+
+			for _, podReport := range workload.Pods {
+				for _, text := range podReport.Violations {
+					control := ParseViolation(text).Control
+					i, ok := index[control]
+					if !ok {
+						i = len(counts)
+						index[control] = i
+						counts = append(counts, ControlCount{Control: control})
+					}
+					counts[i].Violations++
+
+					if key := control + "\x00" + workloadKey; !workloads[key] {
+						workloads[key] = true
+						counts[i].Workloads++
+					}
+				}
+			}
+		}
+	}
+
+	for i := range counts {
+		if report.Summary.Violations > 0 {
+			counts[i].Share = float64(counts[i].Violations) / float64(report.Summary.Violations)
+		}
+	}
+
+	sort.SliceStable(counts, func(i, j int) bool {
+		if counts[i].Violations != counts[j].Violations {
+			return counts[i].Violations > counts[j].Violations
+		}
+		return counts[i].Control < counts[j].Control
+	})
+
+	if n > 0 && len(counts) > n {
+		counts = counts[:n]
+	}
+
+	return counts
+}
+
+// writeTopControls writes the controls as JSON, or as a table for any other
+// output format.
+func writeTopControls(w io.Writer, counts []ControlCount, output string) error {
+	if output == outputJSON {
+		return json.NewEncoder(w).Encode(counts)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONTROL\tVIOLATIONS\tSHARE\tWORKLOADS")
+	for _, count := range counts {
+		fmt.Fprintf(tw, "%s\t%d\t%.0f%%\t%d\n", count.Control, count.Violations, 100*count.Share, count.Workloads)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTopControls(t *testing.T) {
+	report := newReport([]*PSViolation{
+		{
+			Namespace: "alpha",
+			Level:     "restricted",
+			PodViolations: []*PodViolation{
+				{Name: "web-1", Violations: []string{`allowPrivilegeEscalation != false (container "app" must set securityContext.allowPrivilegeEscalation=false)`, "seccompProfile"}},
+				{Name: "web-2", Violations: []string{`allowPrivilegeEscalation != false (container "app" must set securityContext.allowPrivilegeEscalation=false)`}},
+			},
+		},
+		{
+			Namespace: "beta",
+			Level:     "restricted",
+			PodViolations: []*PodViolation{
+				{Name: "db-0", Violations: []string{"allowPrivilegeEscalation != false", "privileged"}},
+			},
+		},
+	})
+
+	for _, tt := range []struct {
+		name string
+		n    int
+		want []ControlCount
+	}{
+		{
+			name: "should count violations and distinct workloads per control",
+			want: []ControlCount{
+				{Control: "allowPrivilegeEscalation != false", Violations: 3, Workloads: 3, Share: 0.6},
+				{Control: "privileged", Violations: 1, Workloads: 1, Share: 0.2},
+				{Control: "seccompProfile", Violations: 1, Workloads: 1, Share: 0.2},
+			},
+		},
+		{
+			name: "should keep only the most violated controls",
+			n:    1,
+			want: []ControlCount{
+				{Control: "allowPrivilegeEscalation != false", Violations: 3, Workloads: 3, Share: 0.6},
+			},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := topControls(report, tt.n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Violation is a single PodSecurity control that a pod violates, as parsed
+// from the admission warning. For example
+//
+//	allowPrivilegeEscalation != false (container "app" must set securityContext.allowPrivilegeEscalation=false)
+//
+// has the control "allowPrivilegeEscalation != false", the container "app"
+// and the text in parentheses as details.
+type Violation struct {
+	Control    string   `json:"control"`
+	Containers []string `json:"containers,omitempty"`
+	Details    string   `json:"details,omitempty"`
+}
+
+// containersRegexp matches the quoted container names in the details of a
+// violation, e.g. `containers "a", "b" must ...`.
+var containersRegexp = regexp.MustCompile(`containers? ((?:"[^"]*"(?:, | and )?)+)`)
+
+// quotedRegexp matches a single quoted name.
+var quotedRegexp = regexp.MustCompile(`"([^"]*)"`)
+
+// ParseViolation splits a violation as found in PodViolation.Violations into
+// its control, the containers it names and its details. Text without details
+// is returned as the control.
+func ParseViolation(text string) Violation {
+	control, details, found := strings.Cut(text, " (")
+	if !found {
+		return Violation{Control: strings.TrimSpace(text)}
+	}
+
+	violation := Violation{
+		Control: strings.TrimSpace(control),
+		Details: strings.TrimSuffix(details, ")"),
+	}
+
+	if match := containersRegexp.FindStringSubmatch(violation.Details); match != nil {
+		for _, name := range quotedRegexp.FindAllStringSubmatch(match[1], -1) {
+			violation.Containers = append(violation.Containers, name[1])
+		}
+	}
+
+	return violation
+}
+
+// genericRemediation is suggested for controls without a known remediation.
+const genericRemediation = "see the Pod Security Standards for the fields this control restricts"
+
+// remediations suggests how to fix a violation, keyed by its control.
+var remediations = map[string]string{
+	"privileged":                        "set securityContext.privileged: false or drop it",
+	"allowPrivilegeEscalation != false": "set securityContext.allowPrivilegeEscalation: false",
+	"unrestricted capabilities":         `set securityContext.capabilities.drop: ["ALL"]`,
+	"non-default capabilities":          "remove the capabilities from securityContext.capabilities.add, restricted only allows NET_BIND_SERVICE",
+	"runAsNonRoot != true":              "set securityContext.runAsNonRoot: true on the pod or every container",
+	"runAsUser=0":                       "set securityContext.runAsUser to a non-zero UID or drop it",
+	"seccompProfile":                    "set securityContext.seccompProfile.type: RuntimeDefault",
+	"host namespaces":                   "drop hostNetwork, hostPID and hostIPC from the pod spec",
+	"hostPort":                          "drop hostPort from the container ports",
+	"hostPath volumes":                  "replace hostPath volumes with emptyDir, configMap or persistent volumes",
+	"restricted volume types":           "use only configMap, csi, downwardAPI, emptyDir, ephemeral, persistentVolumeClaim, projected or secret volumes",
+	"hostProcess":                       "drop securityContext.windowsOptions.hostProcess",
+	"procMount":                         "drop securityContext.procMount or set it to Default",
+	"forbidden sysctls":                 "only set the safe sysctls in securityContext.sysctls",
+	"seLinuxOptions":                    "drop securityContext.seLinuxOptions.type and user, or use an allowed type",
+	"forbidden AppArmor profile":        "use the runtime/default or a localhost AppArmor profile",
+	"forbidden AppArmor profiles":       "use the runtime/default or a localhost AppArmor profile",
+}
+
+// Remediation returns a short suggestion on how to fix the violation.
+func (v Violation) Remediation() string {
+	if remediation, ok := remediations[v.Control]; ok {
+		return remediation
+	}
+
+	return genericRemediation
+}
@@ -0,0 +1,266 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// watchDebounce is how long a namespace has to stay unchanged before it is
+// checked again.
+const watchDebounce = 5 * time.Second
+
+// debouncer remembers the last change of every namespace waiting to be
+// checked. The delaying queue keeps the earliest time of an item that is
+// already waiting, so the last change decides whether it is due. It is safe
+// for concurrent use.
+type debouncer struct {
+	mu      sync.Mutex
+	changed map[string]time.Time
+	now     func() time.Time
+}
+
+func newDebouncer() *debouncer {
+	return &debouncer{
+		changed: map[string]time.Time{},
+		now:     time.Now,
+	}
+}
+
+// touch records a change of the namespace.
+func (d *debouncer) touch(namespace string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.changed[namespace] = d.now()
+}
+
+// remaining returns how long the namespace still has to stay unchanged
+// before it is due. Once it is due, its last change is forgotten.
+func (d *debouncer) remaining(namespace string) time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	changed, ok := d.changed[namespace]
+	if !ok {
+		return 0
+	}
+	if wait := watchDebounce - d.now().Sub(changed); wait > 0 {
+		return wait
+	}
+	delete(d.changed, namespace)
+
+	return 0
+}
+
+// watchNamespaces keeps checking namespaces whenever their labels change or
+// pods are added to or removed from them. Violations that appeared since the
+// last check of a namespace are printed prefixed with "+", violations that
+// were resolved with "-". It runs until ctx is cancelled.
+func watchNamespaces(ctx context.Context, log *slog.Logger, client kubernetes.Interface, filter *namespaceFilter, includeWarn bool, targets *levelTargets, m *metrics, out io.Writer) error {
+	selector, err := labels.Parse(filter.selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector: %w", err)
+	}
+
+	factory := informers.NewSharedInformerFactory(client, 0)
+	namespaceLister := factory.Core().V1().Namespaces().Lister()
+
+	queue := workqueue.NewDelayingQueue()
+	debounce := newDebouncer()
+	enqueue := func(namespace string) {
+		if !filter.excluded(namespace) {
+			debounce.touch(namespace)
+			queue.AddAfter(namespace, watchDebounce)
+		}
+	}
+
+	_, err = factory.Core().V1().Namespaces().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if namespace, ok := obj.(*corev1.Namespace); ok {
+				enqueue(namespace.Name)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNamespace, ok := oldObj.(*corev1.Namespace)
+			if !ok {
+				return
+			}
+			newNamespace, ok := newObj.(*corev1.Namespace)
+			if !ok {
+				return
+			}
+			if !equality.Semantic.DeepEqual(oldNamespace.Labels, newNamespace.Labels) {
+				enqueue(newNamespace.Name)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj); err == nil {
+				enqueue(key)
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = factory.Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if pod, ok := obj.(*corev1.Pod); ok {
+				enqueue(pod.Namespace)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err != nil {
+				return
+			}
+			if namespace, _, err := cache.SplitMetaNamespaceKey(key); err == nil {
+				enqueue(namespace)
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+	for informerType, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("failed to sync informer for %v", informerType)
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		queue.ShutDown()
+	}()
+
+	log.Info("Watching namespaces", "debounce", watchDebounce)
+
+	// previous holds the violations of every namespace at its last check.
+	previous := map[string]map[string]watchedViolation{}
+	for {
+		item, shutdown := queue.Get()
+		if shutdown {
+			return nil
+		}
+		name := item.(string)
+
+		// Wait for the namespace to settle if it changed since it was
+		// queued.
+		if wait := debounce.remaining(name); wait > 0 {
+			queue.AddAfter(item, wait)
+			queue.Done(item)
+			continue
+		}
+
+		current, err := watchedViolations(ctx, client, namespaceLister.Get, selector, name, includeWarn, targets)
+		if err != nil {
+			log.Error("Error checking namespace", "namespace", name, "err", err)
+			queue.Done(item)
+			continue
+		}
+
+		for key, violation := range current {
+			if _, ok := previous[name][key]; !ok {
+				m.observeViolation(violation.namespace, violation.level, violation.violation)
+			}
+		}
+
+		printDelta(out, sets.KeySet(previous[name]), sets.KeySet(current))
+		if len(current) == 0 {
+			delete(previous, name)
+		} else {
+			previous[name] = current
+		}
+		queue.Done(item)
+	}
+}
+
+// watchedViolation is a violation found by the watch, keyed by the line that
+// describes it in the deltas.
+type watchedViolation struct {
+	namespace string
+	level     string
+	violation string
+}
+
+// watchedViolations checks the named namespace and returns its violations
+// by their delta lines. Namespaces that are gone or no longer selected have
+// none.
+func watchedViolations(
+	ctx context.Context,
+	client kubernetes.Interface,
+	getNamespace func(string) (*corev1.Namespace, error),
+	selector labels.Selector,
+	name string,
+	includeWarn bool,
+	targets *levelTargets,
+) (map[string]watchedViolation, error) {
+	current := map[string]watchedViolation{}
+
+	namespace, err := getNamespace(name)
+	if apierrors.IsNotFound(err) {
+		return current, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !selector.Matches(labels.Set(namespace.Labels)) {
+		return current, nil
+	}
+
+	result, err := auditNamespace(ctx, client, namespace, includeWarn, targets)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, psv := range result.violations {
+		for _, podViolation := range psv.PodViolations {
+			kind, workload := podViolation.workload()
+			for _, violation := range podViolation.Violations {
+				key := fmt.Sprintf("%s/%s (%s/%s) [%s %s]: %s", psv.Namespace, podViolation.Name, kind, workload, psv.Mechanism, psv.Level, violation)
+				current[key] = watchedViolation{
+					namespace: psv.Namespace,
+					level:     psv.Level,
+					violation: violation,
+				}
+			}
+		}
+	}
+
+	return current, nil
+}
+
+// printDelta prints the violations that were added or removed between the
+// previous and the current check, in a stable order.
+func printDelta(out io.Writer, previous, current sets.Set[string]) {
+	var lines []string
+	for _, key := range sets.List(current.Difference(previous)) {
+		lines = append(lines, "+ "+key)
+	}
+	for _, key := range sets.List(previous.Difference(current)) {
+		lines = append(lines, "- "+key)
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i][2:] < lines[j][2:] })
+
+	for _, line := range lines {
+		fmt.Fprintln(out, line)
+	}
+}
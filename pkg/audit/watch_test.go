@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDebouncer(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, tt := range []struct {
+		name    string
+		changes []time.Duration
+		at      time.Duration
+		want    time.Duration
+	}{
+		{
+			name: "should be due if the namespace never changed",
+			at:   0,
+			want: 0,
+		},
+		{
+			name:    "should wait for the debounce after a change",
+			changes: []time.Duration{0},
+			at:      2 * time.Second,
+			want:    watchDebounce - 2*time.Second,
+		},
+		{
+			name:    "should wait for the debounce after the last change",
+			changes: []time.Duration{0, 4 * time.Second},
+			at:      5 * time.Second,
+			want:    watchDebounce - time.Second,
+		},
+		{
+			name:    "should be due once the namespace settled",
+			changes: []time.Duration{0, 4 * time.Second},
+			at:      4*time.Second + watchDebounce,
+			want:    0,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			d := newDebouncer()
+			for _, change := range tt.changes {
+				d.now = func() time.Time { return start.Add(change) }
+				d.touch("p0t-sekurity")
+			}
+			d.now = func() time.Time { return start.Add(tt.at) }
+
+			if got := d.remaining("p0t-sekurity"); got != tt.want {
+				t.Errorf("expected to wait %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
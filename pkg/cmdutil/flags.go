@@ -0,0 +1,95 @@
+package cmdutil
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Flags are the flags shared by all commands.
+type Flags struct {
+	Kubeconfig string
+	Context    string
+	Debug      bool
+	Verbosity  int
+	Quiet      bool
+}
+
+// AddFlags registers the shared flags on the flag set.
+func (f *Flags) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&f.Kubeconfig, "kubeconfig", "", "path to the kubeconfig file, defaults to the files listed in $KUBECONFIG, merged, or ~/.kube/config")
+	fs.StringVar(&f.Context, "context", "", "kubeconfig context to use, defaults to the current context")
+	fs.BoolVar(&f.Debug, "debug", false, "enable debug logging, same as --v=1")
+	fs.IntVar(&f.Verbosity, "v", 0, "log verbosity, 0 logs info, 1 debug and higher values even more")
+	fs.BoolVarP(&f.Quiet, "quiet", "q", false, "only log errors and don't report progress, so that stdout holds just the output")
+}
+
+// Logger returns a logger that writes diagnostics to stderr at the level
+// selected by --v and --debug, so that stdout is left for the actual output.
+// With --quiet only errors are logged.
+func (f *Flags) Logger() *slog.Logger {
+	verbosity := f.Verbosity
+	if f.Debug && verbosity < 1 {
+		verbosity = 1
+	}
+
+	level := slog.LevelInfo - slog.Level(4*verbosity)
+	if f.Quiet {
+		level = slog.LevelError
+	}
+
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: level,
+	}))
+}
+
+// ForContext returns a copy of the flags that selects the named context.
+func (f *Flags) ForContext(name string) *Flags {
+	clusterFlags := *f
+	clusterFlags.Context = name
+
+	return &clusterFlags
+}
+
+// loadingRules loads --kubeconfig if set, else merges the files listed in
+// $KUBECONFIG, e.g. KUBECONFIG=a:b:c, else loads ~/.kube/config.
+func (f *Flags) loadingRules() *clientcmd.ClientConfigLoadingRules {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = f.Kubeconfig
+
+	return rules
+}
+
+// KubeconfigPath returns the kubeconfig files to load, separated like in
+// $KUBECONFIG.
+func (f *Flags) KubeconfigPath() string {
+	if f.Kubeconfig != "" {
+		return f.Kubeconfig
+	}
+
+	return strings.Join(f.loadingRules().Precedence, string(filepath.ListSeparator))
+}
+
+// RESTConfig loads the kubeconfig and returns the config for the selected
+// context.
+func (f *Flags) RESTConfig() (*rest.Config, error) {
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: f.Context}
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(f.loadingRules(), overrides).ClientConfig()
+}
+
+// Clientset returns a clientset for the selected context.
+func (f *Flags) Clientset() (*kubernetes.Clientset, error) {
+	config, err := f.RESTConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return kubernetes.NewForConfig(config)
+}
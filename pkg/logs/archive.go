@@ -0,0 +1,67 @@
+package logs
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// logArchive collects the saved logs of all containers in a single
+// gzip-compressed tarball. It is safe for concurrent use.
+type logArchive struct {
+	mu   sync.Mutex
+	file *os.File
+	gz   *gzip.Writer
+	tw   *tar.Writer
+}
+
+// newLogArchive creates the tarball at filename.
+func newLogArchive(filename string) (*logArchive, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(file)
+
+	return &logArchive{
+		file: file,
+		gz:   gz,
+		tw:   tar.NewWriter(gz),
+	}, nil
+}
+
+// archiveEntryName returns the name of a container's logs in the archive,
+// <namespace>/<pod>/<container>.log.
+func archiveEntryName(namespace, pod, container string) string {
+	return path.Join(sanitizeFilename(namespace), sanitizeFilename(pod), sanitizeFilename(container)+".log")
+}
+
+// add writes the size bytes of logs to the archive as name. Tar entries need
+// their size up front, so the logs must have been buffered already.
+func (a *logArchive) add(name string, logs io.Reader, size int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+
+	_, err := io.CopyN(a.tw, logs, size)
+	return err
+}
+
+// close finishes the tarball.
+func (a *logArchive) close() error {
+	return errors.Join(a.tw.Close(), a.gz.Close(), a.file.Close())
+}
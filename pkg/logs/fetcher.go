@@ -0,0 +1,29 @@
+package logs
+
+import (
+	"context"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// LogFetcher opens the log stream of a container of a pod.
+type LogFetcher interface {
+	Stream(ctx context.Context, namespace, pod string, opts *corev1.PodLogOptions) (io.ReadCloser, error)
+}
+
+// clientLogFetcher gets the logs from the apiserver.
+type clientLogFetcher struct {
+	clientset kubernetes.Interface
+}
+
+// NewLogFetcher returns a LogFetcher that gets the logs through clientset.
+func NewLogFetcher(clientset kubernetes.Interface) LogFetcher {
+	return &clientLogFetcher{clientset: clientset}
+}
+
+// Stream implements LogFetcher.
+func (f *clientLogFetcher) Stream(ctx context.Context, namespace, pod string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	return f.clientset.CoreV1().Pods(namespace).GetLogs(pod, opts).Stream(ctx)
+}
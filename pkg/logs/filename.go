@@ -0,0 +1,27 @@
+package logs
+
+import "strings"
+
+// sanitizeFilename turns name into a safe file name by replacing path
+// separators, whitespace and every other character outside of letters,
+// digits, '.', '_' and '-' with '_'. Leading dots are replaced as well, so
+// that the result is neither hidden nor a relative path like "..".
+func sanitizeFilename(name string) string {
+	if name == "" {
+		return "_"
+	}
+
+	sanitized := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '.', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+
+	trimmed := strings.TrimLeft(sanitized, ".")
+	return strings.Repeat("_", len(sanitized)-len(trimmed)) + trimmed
+}
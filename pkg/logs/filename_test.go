@@ -0,0 +1,56 @@
+package logs
+
+import "testing"
+
+func TestSanitizeFilename(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "should keep kubernetes names",
+			input: "logs_openshift-kube-apiserver_kube-apiserver-0.txt",
+			want:  "logs_openshift-kube-apiserver_kube-apiserver-0.txt",
+		},
+		{
+			name:  "should replace spaces",
+			input: "logs_should violate as openshift namespaces don't get synced",
+			want:  "logs_should_violate_as_openshift_namespaces_don_t_get_synced",
+		},
+		{
+			name:  "should replace path separators",
+			input: "a/b\\c",
+			want:  "a_b_c",
+		},
+		{
+			name:  "should not allow parent directories",
+			input: "..",
+			want:  "__",
+		},
+		{
+			name:  "should not allow hidden files",
+			input: ".bashrc",
+			want:  "_bashrc",
+		},
+		{
+			name:  "should replace control and non-ascii characters",
+			input: "tab\there\nnewline-ümlaut",
+			want:  "tab_here_newline-_mlaut",
+		},
+		{
+			name:  "should not return an empty name",
+			input: "",
+			want:  "_",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := sanitizeFilename(tt.input); got != tt.want {
+				t.Errorf("sanitizeFilename(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package logs
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// logIndex maps the containers whose logs were saved to their files, so
+// that automation doesn't need to parse the file names. It is safe for
+// concurrent use. A nil *logIndex records nothing.
+type logIndex struct {
+	Patterns []string     `json:"patterns"`
+	Time     time.Time    `json:"time"`
+	Archive  string       `json:"archive,omitempty"`
+	Logs     []indexEntry `json:"logs"`
+
+	mu sync.Mutex
+}
+
+// indexEntry is a saved log file. With --archive, File is the name of the
+// entry in the archive.
+type indexEntry struct {
+	Container string `json:"container"`
+	File      string `json:"file"`
+	Matches   int    `json:"matches"`
+}
+
+// newLogIndex starts the index of a search run.
+func newLogIndex(patterns []string, archive string) *logIndex {
+	return &logIndex{
+		Patterns: patterns,
+		Time:     time.Now().UTC(),
+		Archive:  archive,
+		Logs:     []indexEntry{},
+	}
+}
+
+// add records the saved logs of the container, named namespace/pod/container.
+func (i *logIndex) add(container, file string, matches int) {
+	if i == nil {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.Logs = append(i.Logs, indexEntry{Container: container, File: file, Matches: matches})
+}
+
+// write writes the index to filename, sorted by container so that runs are
+// comparable.
+func (i *logIndex) write(filename string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	sort.Slice(i.Logs, func(a, b int) bool {
+		return i.Logs[a].Container < i.Logs[b].Container
+	})
+
+	data, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, append(data, '\n'), 0644)
+}
@@ -0,0 +1,1118 @@
+package logs
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/ibihim/kube-plays/pkg/audit"
+	"github.com/ibihim/kube-plays/pkg/cmdutil"
+)
+
+const (
+	controllerName = "pod-security-admission-label-synchronization-controller"
+
+	// defaultPattern matches the controller's log lines.
+	defaultPattern = "= " + controllerName + " ="
+
+	// maxLineLength is the longest log line that can be scanned.
+	maxLineLength = 1024 * 1024
+
+	// qpsPerWorker and burstPerWorker are client-go's defaults for a single
+	// client, scaled with --workers so that the workers aren't starved by the
+	// shared client's rate limiter.
+	qpsPerWorker   = 5
+	burstPerWorker = 10
+
+	// defaultMaxBytes caps the logs searched per container, so that a
+	// runaway container doesn't take the search down with it.
+	defaultMaxBytes = 50 << 20
+)
+
+type options struct {
+	patterns        []string
+	patternFile     string
+	ignoreCase      bool
+	invert          bool
+	createResources bool
+	profileName     string
+	getLogs         bool
+	contextLines    int
+	compress        bool
+	countOnly       bool
+	pageSize        int64
+	namespace       string
+	labelSelector   string
+	fieldSelector   string
+	retries         int
+	limit           int
+	initContainers  bool
+	tail            int64
+	since           time.Duration
+	sinceTime       string
+	timestamps      bool
+	after           string
+	before          string
+	ownerKind       string
+	archive         string
+	index           string
+	follow          bool
+	followTimeout   time.Duration
+	stopOnMatch     bool
+	workers         int
+	output          string
+	maxBytes        int64
+	readiness       readiness
+}
+
+// NewCommand returns the logs command, which searches the logs of all pods
+// for the label synchronization controller's messages.
+func NewCommand(flags *cmdutil.Flags) *cobra.Command {
+	opts := &options{}
+
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Search the logs of all pods for a pattern",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd.Context(), flags, opts)
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&opts.patterns, "pattern", nil, fmt.Sprintf("Pattern to search for in logs, may be repeated to match any of them (default %q)", defaultPattern))
+	cmd.Flags().StringVar(&opts.patternFile, "pattern-file", "", "File with one pattern per line, combined with --pattern; blank lines and # comments are skipped")
+	cmd.Flags().BoolVar(&opts.ignoreCase, "ignore-case", false, "Match the patterns case-insensitively")
+	cmd.Flags().BoolVar(&opts.invert, "invert", false, "Report lines that match none of the patterns; with --count-only the non-matching lines are counted")
+	cmd.Flags().BoolVar(&opts.createResources, "create", false, "Create new namespaces and pods before searching")
+	cmd.Flags().StringVar(&opts.profileName, "profile", "escalation", "Pod profile used for created pods (escalation, privileged, hostpath, rootuser)")
+	cmd.Flags().StringVar(&opts.readiness.mode, "readiness-mode", readinessWatch, "How to wait for created pods to run, watch or poll; poll works behind proxies that break watches")
+	cmd.Flags().DurationVar(&opts.readiness.interval, "readiness-interval", time.Second, "How often to check created pods with --readiness-mode poll")
+	cmd.Flags().DurationVar(&opts.readiness.timeout, "readiness-timeout", time.Minute, "How long to wait for each created pod to run")
+	cmd.Flags().BoolVar(&opts.getLogs, "logs", true, "Get logs for the controller")
+	cmd.Flags().IntVarP(&opts.contextLines, "context-lines", "C", 0, "Print this many lines of context around each match")
+	cmd.Flags().BoolVar(&opts.compress, "compress", false, "Gzip the saved log files")
+	cmd.Flags().BoolVar(&opts.countOnly, "count-only", false, "Only print the number of matches per pod and in total, without saving logs")
+	cmd.Flags().StringVarP(&opts.namespace, "namespace", "n", "", "Only search pods in this namespace (default all namespaces)")
+	cmd.Flags().StringVarP(&opts.labelSelector, "selector", "l", "", "Only search pods matching this label selector")
+	cmd.Flags().StringVar(&opts.fieldSelector, "field-selector", "", "Only search pods matching this field selector, e.g. status.phase=Running")
+	cmd.Flags().IntVar(&opts.retries, "retries", 3, "Retry opening a pod's log stream this many times on transient errors")
+	cmd.Flags().IntVar(&opts.limit, "limit", 0, "Stop after searching this many pods, 0 searches all of them")
+	cmd.Flags().BoolVar(&opts.initContainers, "init-containers", true, "Also search the logs of init containers that ran")
+	cmd.Flags().Int64Var(&opts.tail, "tail", 0, "Only search the last this many lines of each container's logs, 0 searches all")
+	cmd.Flags().DurationVar(&opts.since, "since", 0, "Only search logs newer than this duration, e.g. 1h; combined with --tail only the last lines within it")
+	cmd.Flags().StringVar(&opts.sinceTime, "since-time", "", "Only search logs newer than this RFC3339 timestamp, e.g. 2024-06-01T12:00:00Z")
+	cmd.Flags().BoolVar(&opts.timestamps, "timestamps", false, "Get the logs with the kubelet's timestamp in front of each line")
+	cmd.Flags().StringVar(&opts.after, "after", "", "Only report matches timestamped at or after this RFC3339 time, implies --timestamps")
+	cmd.Flags().StringVar(&opts.before, "before", "", "Only report matches timestamped at or before this RFC3339 time, implies --timestamps")
+	cmd.Flags().StringVar(&opts.ownerKind, "owner-kind", "", "Only search pods whose top-most owner is of this kind, e.g. Deployment, DaemonSet or Pod for bare pods")
+	cmd.Flags().StringVar(&opts.archive, "archive", "", "Save the logs with matches into this gzip-compressed tarball instead of one file per container, e.g. out.tar.gz")
+	cmd.Flags().StringVar(&opts.index, "index", "", "Write a JSON index of the saved logs with their containers and match counts to this file, e.g. index.json")
+	cmd.Flags().BoolVarP(&opts.follow, "follow", "f", false, "Keep streaming the logs of running containers until interrupted, --follow-timeout passes or, with --stop-on-match, a line matches")
+	cmd.Flags().DurationVar(&opts.followTimeout, "follow-timeout", 0, "Stop following the logs after this duration and fail if nothing matched, 0 follows until interrupted")
+	cmd.Flags().BoolVar(&opts.stopOnMatch, "stop-on-match", false, "Stop following the logs of all pods at the first match")
+	cmd.Flags().IntVar(&opts.workers, "workers", 10, "Search the logs of this many pods in parallel; followed pods aren't limited")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", outputText, "Output format of the matches, text or json; json prints an array of the matches per container at the end instead of the matching lines")
+	cmd.Flags().Int64Var(&opts.maxBytes, "max-bytes", defaultMaxBytes, "Only search the first this many bytes of each container's logs, 0 searches all of them")
+	cmd.Flags().Int64Var(&opts.pageSize, "page-size", 0, "List pods in pages of this size, 0 lists them all at once")
+
+	return cmd
+}
+
+func run(ctx context.Context, flags *cmdutil.Flags, opts *options) error {
+	patterns := opts.patterns
+	if opts.patternFile != "" {
+		filePatterns, err := readPatternFile(opts.patternFile)
+		if err != nil {
+			return fmt.Errorf("error reading pattern file: %w", err)
+		}
+		patterns = append(patterns, filePatterns...)
+	}
+
+	if len(patterns) == 0 {
+		patterns = []string{defaultPattern}
+	}
+
+	if opts.archive != "" && (opts.compress || opts.countOnly) {
+		return errors.New("--archive can't be combined with --compress or --count-only")
+	}
+	if opts.index != "" && opts.countOnly {
+		return errors.New("--index can't be combined with --count-only")
+	}
+
+	if opts.output != outputText && opts.output != outputJSON {
+		return fmt.Errorf("unknown output format %q, must be text or json", opts.output)
+	}
+
+	if err := opts.readiness.validate(); err != nil {
+		return err
+	}
+
+	if opts.maxBytes < 0 {
+		return errors.New("--max-bytes must not be negative")
+	}
+
+	if opts.workers < 1 {
+		return errors.New("--workers must be positive")
+	}
+
+	if opts.retries < 0 {
+		return errors.New("--retries must not be negative")
+	}
+
+	if opts.follow && opts.countOnly {
+		return errors.New("--follow can't be combined with --count-only")
+	}
+	if !opts.follow && (opts.followTimeout > 0 || opts.stopOnMatch) {
+		return errors.New("--follow-timeout and --stop-on-match require --follow")
+	}
+
+	if opts.since > 0 && opts.sinceTime != "" {
+		return errors.New("only one of --since and --since-time may be used")
+	}
+
+	var sinceTime *metav1.Time
+	if opts.sinceTime != "" {
+		t, err := time.Parse(time.RFC3339, opts.sinceTime)
+		if err != nil {
+			return fmt.Errorf("invalid --since-time: %w", err)
+		}
+		sinceTime = &metav1.Time{Time: t}
+	}
+
+	var window timeWindow
+	for _, bound := range []struct {
+		flag  string
+		value string
+		t     *time.Time
+	}{
+		{flag: "--after", value: opts.after, t: &window.after},
+		{flag: "--before", value: opts.before, t: &window.before},
+	} {
+		if bound.value == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, bound.value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", bound.flag, err)
+		}
+		*bound.t = t
+		opts.timestamps = true
+	}
+
+	// Validate the selectors before talking to the cluster.
+	if _, err := labels.Parse(opts.labelSelector); err != nil {
+		return fmt.Errorf("invalid label selector: %w", err)
+	}
+	if _, err := fields.ParseSelector(opts.fieldSelector); err != nil {
+		return fmt.Errorf("invalid field selector: %w", err)
+	}
+
+	log := flags.Logger()
+	log.Debug("Options",
+		"patterns", patterns,
+		"ignoreCase", opts.ignoreCase,
+		"invert", opts.invert,
+		"createResources", opts.createResources,
+		"readinessMode", opts.readiness.mode,
+		"profile", opts.profileName,
+		"getLogs", opts.getLogs,
+		"contextLines", opts.contextLines,
+		"compress", opts.compress,
+		"countOnly", opts.countOnly,
+		"namespace", opts.namespace,
+		"labelSelector", opts.labelSelector,
+		"fieldSelector", opts.fieldSelector,
+		"retries", opts.retries,
+		"limit", opts.limit,
+		"initContainers", opts.initContainers,
+		"tail", opts.tail,
+		"since", opts.since,
+		"sinceTime", opts.sinceTime,
+		"timestamps", opts.timestamps,
+		"after", opts.after,
+		"before", opts.before,
+		"ownerKind", opts.ownerKind,
+		"archive", opts.archive,
+		"index", opts.index,
+		"follow", opts.follow,
+		"followTimeout", opts.followTimeout,
+		"stopOnMatch", opts.stopOnMatch,
+		"workers", opts.workers,
+		"output", opts.output,
+		"maxBytes", opts.maxBytes,
+		"kubeconfig", flags.KubeconfigPath(),
+		"context", flags.Context,
+	)
+
+	// Create the clientset, shared by all workers.
+	config, err := flags.RESTConfig()
+	if err != nil {
+		return err
+	}
+	config.QPS = float32(qpsPerWorker * opts.workers)
+	config.Burst = burstPerWorker * opts.workers
+	log.Debug("Client rate limit", "qps", config.QPS, "burst", config.Burst)
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	// Create namespaces and pods
+	if opts.createResources {
+		profile, ok := podProfiles[opts.profileName]
+		if !ok {
+			return fmt.Errorf("unknown pod profile %q", opts.profileName)
+		}
+
+		// Namespace 1
+		err = createNamespaceAndPod(ctx, log, clientset, opts.readiness, "test-namespace-1", map[string]string{
+			"pod-security.kubernetes.io/warn":                "restricted",
+			"pod-security.kubernetes.io/audit":               "restricted",
+			"security.openshift.io/scc.podSecurityLabelSync": "false",
+		}, controllerName, profile)
+		if err != nil {
+			return fmt.Errorf("error creating namespace and pod 1: %w", err)
+		}
+
+		// Namespace 2
+		err = createNamespaceAndPod(ctx, log, clientset, opts.readiness, "openshift-test-namespace-2", nil, "", profile)
+		if err != nil {
+			return fmt.Errorf("error creating namespace and pod 2: %w", err)
+		}
+
+		// Namespace 3
+		err = createNamespaceAndPod(ctx, log, clientset, opts.readiness, "test-namespace-3", map[string]string{
+			"pod-security.kubernetes.io/warn":  "restricted",
+			"pod-security.kubernetes.io/audit": "restricted",
+		}, "kubectl-edit", profile)
+		if err != nil {
+			return fmt.Errorf("error creating namespace and pod 3: %w", err)
+		}
+	}
+
+	if opts.getLogs {
+		m, err := newMatcher(patterns, opts.ignoreCase, opts.invert)
+		if err != nil {
+			return err
+		}
+		m.timestamps = opts.timestamps
+		m.window = window
+
+		// Followed log streams only end when searchCtx is done, which
+		// leaves listing the pods to ctx.
+		var (
+			searchCtx context.Context
+			cancel    context.CancelFunc
+		)
+		if opts.followTimeout > 0 {
+			searchCtx, cancel = context.WithTimeout(ctx, opts.followTimeout)
+		} else {
+			searchCtx, cancel = context.WithCancel(ctx)
+		}
+		defer cancel()
+		if opts.stopOnMatch {
+			m.onMatch = cancel
+		}
+
+		var archive *logArchive
+		if opts.archive != "" {
+			archive, err = newLogArchive(opts.archive)
+			if err != nil {
+				return fmt.Errorf("error creating archive: %w", err)
+			}
+		}
+
+		var index *logIndex
+		if opts.index != "" {
+			index = newLogIndex(patterns, opts.archive)
+		}
+
+		// With json the matching lines aren't printed, only the results
+		// at the end.
+		var (
+			out     io.Writer = os.Stdout
+			results *matchResults
+		)
+		if opts.output == outputJSON {
+			out = io.Discard
+			results = newMatchResults(opts.archive)
+		}
+
+		searchOpts := searchOptions{
+			matcher:        m,
+			contextLines:   opts.contextLines,
+			compress:       opts.compress,
+			countOnly:      opts.countOnly,
+			retries:        opts.retries,
+			initContainers: opts.initContainers,
+			tail:           opts.tail,
+			since:          opts.since,
+			sinceTime:      sinceTime,
+			timestamps:     opts.timestamps,
+			follow:         opts.follow,
+			maxBytes:       opts.maxBytes,
+			archive:        archive,
+			index:          index,
+			results:        results,
+			out:            out,
+			log:            log,
+		}
+		fetcher := NewLogFetcher(clientset)
+
+		var (
+			wg       sync.WaitGroup
+			total    atomic.Int64
+			launched int
+			pods     = make(chan corev1.Pod)
+		)
+		startWorker := func() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for pod := range pods {
+					total.Add(int64(searchPodLogs(searchCtx, fetcher, &pod, searchOpts)))
+				}
+			}()
+		}
+		for i := 0; i < opts.workers; i++ {
+			startWorker()
+		}
+
+		// Get the selected pods, searching each page as it arrives.
+		listOpts := metav1.ListOptions{
+			LabelSelector: opts.labelSelector,
+			FieldSelector: opts.fieldSelector,
+			Limit:         opts.pageSize,
+		}
+	list:
+		for {
+			podList, err := clientset.CoreV1().Pods(opts.namespace).List(ctx, listOpts)
+			if err != nil {
+				close(pods)
+				wg.Wait()
+				if archive != nil {
+					_ = archive.close()
+				}
+				return err
+			}
+
+			for _, pod := range podList.Items {
+				if opts.limit > 0 && launched >= opts.limit {
+					log.Debug("Reached the pod limit", "limit", opts.limit)
+					break list
+				}
+
+				// Pods without logs to read don't count towards --limit.
+				if reason := notRunningReason(&pod); reason != "" {
+					log.Info("Skipped (not running)", "namespace", pod.Namespace, "pod", pod.Name, "reason", reason)
+					continue
+				}
+
+				if opts.ownerKind != "" {
+					kind, _, err := audit.TopOwner(ctx, clientset, &pod)
+					if err != nil {
+						log.Error("Error resolving owner", "namespace", pod.Namespace, "pod", pod.Name, "err", err)
+						continue
+					}
+					if !strings.EqualFold(kind, opts.ownerKind) {
+						continue
+					}
+				}
+
+				if opts.follow {
+					// Followed log streams don't end, so every pod
+					// needs a worker of its own.
+					startWorker()
+				}
+				launched++
+				pods <- pod
+			}
+
+			if podList.Continue == "" {
+				break
+			}
+			listOpts.Continue = podList.Continue
+		}
+
+		close(pods)
+		wg.Wait()
+		if archive != nil {
+			if err := archive.close(); err != nil {
+				return fmt.Errorf("error writing archive: %w", err)
+			}
+			log.Info("Logs archived", "file", opts.archive)
+		}
+		if index != nil {
+			// Written once all searches are done, so that it is complete.
+			if err := index.write(opts.index); err != nil {
+				return fmt.Errorf("error writing index: %w", err)
+			}
+			log.Info("Index written", "file", opts.index, "logs", len(index.Logs))
+		}
+		switch {
+		case results != nil:
+			if err := results.write(os.Stdout); err != nil {
+				return fmt.Errorf("error writing results: %w", err)
+			}
+		case opts.countOnly:
+			fmt.Printf("Total: %d\n", total.Load())
+		}
+		log.Info("Search completed", "pods", launched)
+
+		if opts.follow && total.Load() == 0 && errors.Is(searchCtx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("no match within --follow-timeout %s", opts.followTimeout)
+		}
+	}
+
+	return nil
+}
+
+func createNamespaceAndPod(
+	ctx context.Context,
+	log *slog.Logger,
+	clientset kubernetes.Interface,
+	r readiness,
+	nsName string,
+	nsLabels map[string]string,
+	fieldManager string,
+	profile PodProfile,
+) error {
+	// Create namespace
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: nsName,
+		},
+	}
+
+	if nsLabels != nil {
+		namespace.ObjectMeta.Labels = nsLabels
+	}
+
+	opts := metav1.CreateOptions{}
+
+	if fieldManager != "" {
+		opts.FieldManager = fieldManager
+	}
+
+	// Resources left over from a previous run are reused as they are, so
+	// that reruns don't fail.
+	_, err := clientset.CoreV1().Namespaces().Create(ctx, namespace, opts)
+	switch {
+	case apierrors.IsAlreadyExists(err):
+		log.Info("Namespace already exists, reusing it without updating its labels", "namespace", nsName)
+	case err != nil:
+		return fmt.Errorf("error creating namespace: %v", err)
+	}
+
+	pod := profile.pod(nsName, "test-pod")
+	_, err = clientset.CoreV1().Pods(nsName).Create(ctx, pod, metav1.CreateOptions{})
+	switch {
+	case apierrors.IsAlreadyExists(err):
+		log.Info("Pod already exists, reusing it", "namespace", nsName)
+	case err != nil:
+		return fmt.Errorf("error creating pod: %v", err)
+	default:
+		log.Info("Pod created successfully", "namespace", nsName)
+	}
+
+	// Wait for the pod to be running
+	err = waitForPodRunning(ctx, clientset, nsName, "test-pod", r)
+	if err != nil {
+		return fmt.Errorf("error waiting for pod to be running: %v", err)
+	}
+	log.Info("Pod is now running", "namespace", nsName)
+
+	return nil
+}
+
+// PodProfile describes the PodSecurity violations a test pod should carry.
+type PodProfile struct {
+	// Privileged runs the container in privileged mode.
+	Privileged bool
+	// AllowPrivilegeEscalation lets the container gain more privileges
+	// than its parent process.
+	AllowPrivilegeEscalation bool
+	// HostPath mounts the given host directory into the container.
+	HostPath string
+	// RunAsRoot runs the container as UID 0.
+	RunAsRoot bool
+	// AddCapabilities adds the given Linux capabilities to the container.
+	AddCapabilities []corev1.Capability
+}
+
+// podProfiles are the named presets selectable with --profile.
+var podProfiles = map[string]PodProfile{
+	"escalation": {AllowPrivilegeEscalation: true},
+	"privileged": {Privileged: true},
+	"hostpath":   {HostPath: "/var/log"},
+	"rootuser":   {RunAsRoot: true, AddCapabilities: []corev1.Capability{"NET_ADMIN", "SYS_TIME"}},
+}
+
+// pod builds a busybox pod that violates PodSecurity as described by the
+// profile.
+func (p PodProfile) pod(namespace, name string) *corev1.Pod {
+	securityContext := &corev1.SecurityContext{}
+	if p.AllowPrivilegeEscalation {
+		securityContext.AllowPrivilegeEscalation = boolPtr(true)
+	}
+	if p.Privileged {
+		securityContext.Privileged = boolPtr(true)
+	}
+	if p.RunAsRoot {
+		securityContext.RunAsUser = int64Ptr(0)
+		securityContext.RunAsNonRoot = boolPtr(false)
+	}
+	if len(p.AddCapabilities) > 0 {
+		securityContext.Capabilities = &corev1.Capabilities{
+			Add: p.AddCapabilities,
+		}
+	}
+
+	container := corev1.Container{
+		Name:  "test-container",
+		Image: "busybox",
+		Command: []string{
+			"sh",
+			"-c",
+			"echo 'Pod is running'; sleep infinity",
+		},
+		SecurityContext: securityContext,
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{container},
+		},
+	}
+
+	if p.HostPath != "" {
+		pod.Spec.Volumes = []corev1.Volume{
+			{
+				Name: "host",
+				VolumeSource: corev1.VolumeSource{
+					HostPath: &corev1.HostPathVolumeSource{
+						Path: p.HostPath,
+					},
+				},
+			},
+		}
+		pod.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{
+			{
+				Name:      "host",
+				MountPath: "/host",
+				ReadOnly:  true,
+			},
+		}
+	}
+
+	return pod
+}
+
+// searchOptions configure how searchPodLogs matches and saves logs.
+type searchOptions struct {
+	matcher        *matcher
+	contextLines   int
+	compress       bool
+	countOnly      bool
+	retries        int
+	initContainers bool
+	tail           int64
+	since          time.Duration
+	sinceTime      *metav1.Time
+	timestamps     bool
+	// follow keeps streaming the logs of the current container instances
+	// until the context is done.
+	follow bool
+	// maxBytes caps the logs searched per container, 0 doesn't cap them.
+	maxBytes int64
+	archive  *logArchive
+	index    *logIndex
+	results  *matchResults
+	// out receives the matches, and dir the saved log files, defaulting to
+	// the working directory.
+	out io.Writer
+	dir string
+	log *slog.Logger
+}
+
+// podLogOptions returns the options to get the logs of the container.
+func (o searchOptions) podLogOptions(container logContainer) *corev1.PodLogOptions {
+	podLogOpts := &corev1.PodLogOptions{
+		Container: container.name,
+		Previous:  container.previous,
+	}
+	if o.tail > 0 {
+		podLogOpts.TailLines = &o.tail
+	}
+	if o.since > 0 {
+		// The kubelet only takes whole seconds, round up to not miss any.
+		sinceSeconds := int64(math.Ceil(o.since.Seconds()))
+		podLogOpts.SinceSeconds = &sinceSeconds
+	}
+	if o.sinceTime != nil {
+		podLogOpts.SinceTime = o.sinceTime
+	}
+	podLogOpts.Timestamps = o.timestamps
+	// The logs of a previous instance won't grow anymore.
+	podLogOpts.Follow = o.follow && !container.previous
+
+	return podLogOpts
+}
+
+// searchPodLogs searches the logs of every started container of the pod and
+// returns the number of matching lines. Pods that aren't running, see
+// notRunningReason, are skipped before they get here.
+func searchPodLogs(ctx context.Context, fetcher LogFetcher, pod *corev1.Pod, opts searchOptions) int {
+	containers := startedContainers(pod, opts.initContainers)
+	if opts.follow {
+		// Followed streams don't end, so every container needs its own.
+		var (
+			wg      sync.WaitGroup
+			matches atomic.Int64
+		)
+		for _, container := range containers {
+			wg.Add(1)
+			go func(container logContainer) {
+				defer wg.Done()
+				matches.Add(int64(searchContainerLogs(ctx, fetcher, pod, container, opts)))
+			}(container)
+		}
+		wg.Wait()
+
+		return int(matches.Load())
+	}
+
+	var matches int
+	for _, container := range containers {
+		matches += searchContainerLogs(ctx, fetcher, pod, container, opts)
+	}
+
+	return matches
+}
+
+// logContainer is a container of a pod whose logs are searched.
+type logContainer struct {
+	name string
+	init bool
+	// restarted is set for containers that restarted, whose previous and
+	// current instance are searched separately, and previous selects the
+	// former.
+	restarted bool
+	previous  bool
+}
+
+// label names the container in matches and file names. Init containers are
+// prefixed with "init:".
+func (c logContainer) label() string {
+	if c.init {
+		return "init:" + c.name
+	}
+
+	return c.name
+}
+
+// instance tags the logs of a restarted container as "previous" or
+// "current". It is empty for containers that didn't restart.
+func (c logContainer) instance() string {
+	switch {
+	case !c.restarted:
+		return ""
+	case c.previous:
+		return "previous"
+	default:
+		return "current"
+	}
+}
+
+// tagged returns the label with the instance, e.g. "app[previous]".
+func (c logContainer) tagged() string {
+	if instance := c.instance(); instance != "" {
+		return c.label() + "[" + instance + "]"
+	}
+
+	return c.label()
+}
+
+// startedContainers returns the containers of the pod that have started at
+// least once and thus have logs, init containers first if includeInit is set.
+// Containers that restarted are returned twice, their previous instance
+// before the current one, so that evidence split across a restart is found.
+func startedContainers(pod *corev1.Pod, includeInit bool) []logContainer {
+	statusesByName := func(statuses []corev1.ContainerStatus) map[string]corev1.ContainerStatus {
+		byName := map[string]corev1.ContainerStatus{}
+		for _, status := range statuses {
+			byName[status.Name] = status
+		}
+		return byName
+	}
+
+	var containers []logContainer
+	add := func(name string, init bool, statuses map[string]corev1.ContainerStatus) {
+		status, ok := statuses[name]
+		if !ok {
+			return
+		}
+
+		restarted := status.RestartCount > 0 && status.LastTerminationState.Terminated != nil
+		if restarted {
+			containers = append(containers, logContainer{name: name, init: init, restarted: true, previous: true})
+		}
+		if status.State.Running != nil || status.State.Terminated != nil || (!restarted && status.LastTerminationState.Terminated != nil) {
+			containers = append(containers, logContainer{name: name, init: init, restarted: restarted})
+		}
+	}
+
+	if includeInit {
+		initStatuses := statusesByName(pod.Status.InitContainerStatuses)
+		for _, container := range pod.Spec.InitContainers {
+			add(container.Name, true, initStatuses)
+		}
+	}
+
+	containerStatuses := statusesByName(pod.Status.ContainerStatuses)
+	for _, container := range pod.Spec.Containers {
+		add(container.Name, false, containerStatuses)
+	}
+
+	return containers
+}
+
+// searchContainerLogs searches the logs of a single container of the pod and
+// returns the number of matching lines.
+func searchContainerLogs(ctx context.Context, fetcher LogFetcher, pod *corev1.Pod, container logContainer, opts searchOptions) int {
+	log := opts.log.With("namespace", pod.Namespace, "pod", pod.Name, "container", container.label())
+	if instance := container.instance(); instance != "" {
+		log = log.With("instance", instance)
+	}
+
+	podLogs, err := openLogStream(ctx, fetcher, pod, container, opts)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error("Error opening log stream", "err", err)
+		}
+		return 0
+	}
+	defer podLogs.Close()
+
+	logs := limitLogs(podLogs, opts.maxBytes)
+	defer func() {
+		if logs.truncated() {
+			log.Warn("Logs truncated, only their beginning was searched", "maxBytes", opts.maxBytes)
+		}
+	}()
+
+	prefix := fmt.Sprintf("%s/%s/%s", pod.Namespace, pod.Name, container.tagged())
+
+	if opts.countOnly {
+		matches, err := scanLogs(logs, opts.matcher, 0, io.Discard, "")
+		if err != nil {
+			log.Error("Error reading logs", "err", err)
+		}
+		if logs.truncated() {
+			fmt.Fprintf(opts.out, "%s: %d (truncated)\n", prefix, matches)
+		} else {
+			fmt.Fprintf(opts.out, "%s: %d\n", prefix, matches)
+		}
+		opts.results.add(pod.Namespace, pod.Name, container.tagged(), "", matches, logs.truncated())
+
+		return matches
+	}
+
+	if opts.archive != nil {
+		return archiveContainerLogs(ctx, logs, pod, container, opts, log, prefix)
+	}
+
+	// Tee the logs into a file while scanning, so that they don't need to be
+	// held in memory. The file is removed again if nothing matched.
+	filename := filepath.Join(opts.dir, sanitizeFilename(fmt.Sprintf("logs_%s_%s_%s_%s.txt", pod.Namespace, pod.Name, container.tagged(), time.Now().Format("20060102_150405"))))
+	if opts.compress {
+		filename += ".gz"
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		log.Error("Error saving logs", "err", err)
+		return 0
+	}
+	defer file.Close()
+
+	var saved io.WriteCloser = file
+	if opts.compress {
+		saved = gzip.NewWriter(file)
+	}
+
+	matches, err := scanLogs(io.TeeReader(logs, saved), opts.matcher, opts.contextLines, opts.out, prefix)
+	if err != nil && ctx.Err() == nil {
+		log.Error("Error reading logs", "err", err)
+	}
+	if err := saved.Close(); err != nil {
+		log.Error("Error saving logs", "err", err)
+	}
+
+	if matches > 0 {
+		log.Info("Found matches, logs saved", "matches", matches, "file", filename)
+		opts.index.add(prefix, filename, matches)
+		opts.results.add(pod.Namespace, pod.Name, container.tagged(), filename, matches, logs.truncated())
+		return matches
+	}
+
+	log.Debug("No matches found")
+	if err := os.Remove(filename); err != nil {
+		log.Error("Error removing file", "file", filename, "err", err)
+	}
+
+	return 0
+}
+
+// archiveContainerLogs scans the logs of a container and adds them to the
+// archive if they match. The logs are buffered in a temporary file while
+// scanning, because tar entries need their size up front.
+func archiveContainerLogs(ctx context.Context, podLogs *limitedLogs, pod *corev1.Pod, container logContainer, opts searchOptions, log *slog.Logger, prefix string) int {
+	tmp, err := os.CreateTemp("", "kube-plays-logs-*")
+	if err != nil {
+		log.Error("Error buffering logs", "err", err)
+		return 0
+	}
+	defer func() {
+		tmp.Close()
+		if err := os.Remove(tmp.Name()); err != nil {
+			log.Error("Error removing file", "file", tmp.Name(), "err", err)
+		}
+	}()
+
+	matches, err := scanLogs(io.TeeReader(podLogs, tmp), opts.matcher, opts.contextLines, opts.out, prefix)
+	if err != nil && ctx.Err() == nil {
+		log.Error("Error reading logs", "err", err)
+	}
+	if matches == 0 {
+		log.Debug("No matches found")
+		return 0
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		log.Error("Error archiving logs", "err", err)
+		return matches
+	}
+
+	name := archiveEntryName(pod.Namespace, pod.Name, container.tagged())
+	if err := opts.archive.add(name, tmp, size); err != nil {
+		log.Error("Error archiving logs", "err", err)
+		return matches
+	}
+	log.Info("Found matches, logs archived", "matches", matches, "entry", name)
+	opts.index.add(prefix, name, matches)
+	opts.results.add(pod.Namespace, pod.Name, container.tagged(), name, matches, podLogs.truncated())
+
+	return matches
+}
+
+// limitedLogs reads logs up to a maximum number of bytes and remembers
+// whether it cut them off.
+type limitedLogs struct {
+	r         io.Reader
+	remaining int64
+	limited   bool
+}
+
+// limitLogs caps the logs read from r at maxBytes, 0 doesn't cap them.
+func limitLogs(r io.Reader, maxBytes int64) *limitedLogs {
+	if maxBytes <= 0 {
+		return &limitedLogs{r: r}
+	}
+
+	return &limitedLogs{r: io.LimitReader(r, maxBytes), remaining: maxBytes, limited: true}
+}
+
+func (l *limitedLogs) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+
+	return n, err
+}
+
+// truncated reports whether the logs were read up to the limit. Logs of
+// exactly the limit's size count as truncated, as telling them apart would
+// need another read, which blocks on followed logs.
+func (l *limitedLogs) truncated() bool {
+	return l.limited && l.remaining <= 0
+}
+
+// openLogStream opens the pod's log stream, retrying transient failures with
+// exponential backoff up to opts.retries times.
+func openLogStream(ctx context.Context, fetcher LogFetcher, pod *corev1.Pod, container logContainer, opts searchOptions) (io.ReadCloser, error) {
+	podLogOpts := opts.podLogOptions(container)
+
+	backoff := wait.Backoff{
+		Duration: 500 * time.Millisecond,
+		Factor:   2,
+		Jitter:   0.1,
+		Steps:    opts.retries + 1,
+	}
+
+	var (
+		podLogs io.ReadCloser
+		lastErr error
+	)
+	err := wait.ExponentialBackoffWithContext(ctx, backoff, func(ctx context.Context) (bool, error) {
+		stream, err := fetcher.Stream(ctx, pod.Namespace, pod.Name, podLogOpts)
+		switch {
+		case err == nil:
+			podLogs = stream
+			return true, nil
+		case isTransient(err):
+			opts.log.Debug("Retrying log stream", "namespace", pod.Namespace, "pod", pod.Name, "container", container.tagged(), "err", err)
+			lastErr = err
+			return false, nil
+		default:
+			return false, err
+		}
+	})
+	if wait.Interrupted(err) && lastErr != nil {
+		return nil, lastErr
+	}
+
+	return podLogs, err
+}
+
+// isTransient reports whether err is worth retrying. Missing pods and bad
+// requests, such as asking for the logs of a container that isn't running,
+// won't go away on their own.
+func isTransient(err error) bool {
+	switch {
+	case apierrors.IsNotFound(err), apierrors.IsBadRequest(err), apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		return false
+	case apierrors.IsInternalError(err), apierrors.IsServerTimeout(err), apierrors.IsTimeout(err),
+		apierrors.IsTooManyRequests(err), apierrors.IsServiceUnavailable(err), apierrors.IsUnexpectedServerError(err):
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return utilnet.IsConnectionReset(err) || utilnet.IsConnectionRefused(err) || utilnet.IsProbableEOF(err)
+}
+
+// notRunningReason returns why the pod has no logs to read, or an empty
+// string if at least one of its containers has started. Pods that are still
+// pending or that failed before starting a container would otherwise fail to
+// open the log stream.
+func notRunningReason(pod *corev1.Pod) string {
+	var waitingReason string
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Running != nil || status.State.Terminated != nil || status.LastTerminationState.Terminated != nil {
+			return ""
+		}
+		if status.State.Waiting != nil && waitingReason == "" {
+			waitingReason = status.State.Waiting.Reason
+		}
+	}
+
+	switch {
+	case waitingReason != "":
+		return waitingReason
+	case pod.Status.Reason != "":
+		return pod.Status.Reason
+	case pod.Status.Phase == corev1.PodRunning:
+		return ""
+	default:
+		return string(pod.Status.Phase)
+	}
+}
+
+// scanLogs reads the logs line by line and writes every line matching m to
+// out, prefixed with prefix and, if m has several patterns, the pattern that
+// hit. If contextLines is positive, that many lines
+// before and after each match are written as well, grep style. Only the
+// context lines are buffered, so memory stays bounded regardless of the log
+// size. It returns the number of matching lines.
+func scanLogs(logs io.Reader, m *matcher, contextLines int, out io.Writer, prefix string) (int, error) {
+	scanner := bufio.NewScanner(logs)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+
+	var (
+		matches    int
+		before     []string
+		afterLeft  int
+		lastOutput = -1
+	)
+
+	for lineNo := 0; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+
+		pattern, ok := m.match(line)
+		if !ok {
+			if afterLeft > 0 {
+				fmt.Fprintf(out, "%s- %s\n", prefix, line)
+				lastOutput = lineNo
+				afterLeft--
+				continue
+			}
+
+			if contextLines > 0 {
+				if len(before) == contextLines {
+					before = before[1:]
+				}
+				before = append(before, line)
+			}
+			continue
+		}
+
+		matches++
+		if m.onMatch != nil {
+			m.onMatch()
+		}
+		if contextLines > 0 && lastOutput >= 0 && lineNo-len(before) > lastOutput+1 {
+			fmt.Fprintln(out, "--")
+		}
+		for _, b := range before {
+			fmt.Fprintf(out, "%s- %s\n", prefix, b)
+		}
+		before = before[:0]
+		if pattern != "" && len(m.patterns) > 1 {
+			fmt.Fprintf(out, "%s: [%s] %s\n", prefix, pattern, line)
+		} else {
+			fmt.Fprintf(out, "%s: %s\n", prefix, line)
+		}
+		lastOutput = lineNo
+		afterLeft = contextLines
+	}
+
+	return matches, scanner.Err()
+}
+
+func boolPtr(b bool) *bool    { return &b }
+func int32Ptr(i int32) *int32 { return &i }
+func int64Ptr(i int64) *int64 { return &i }
@@ -1,4 +1,4 @@
-package main
+package logs
 
 import (
 	"bytes"
@@ -13,9 +13,11 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/ibihim/kube-plays/pkg/cmdutil"
 )
 
 func TestOpenShiftNamespace(t *testing.T) {
@@ -42,7 +44,7 @@ func TestOpenShiftNamespace(t *testing.T) {
 		clusterRole,
 		metav1.CreateOptions{},
 	)
-	if err != nil {
+	if err != nil && !apierrors.IsAlreadyExists(err) {
 		t.Fatalf("failed to create cluster role: %v", err)
 	}
 
@@ -95,8 +97,9 @@ func TestOpenShiftNamespace(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			_, err = clientset.CoreV1().Namespaces().Create(context.TODO(), tt.namespace, tt.options)
-			if err != nil {
+			// Resources left over from a previous run are reused.
+			_, err := clientset.CoreV1().Namespaces().Create(context.TODO(), tt.namespace, tt.options)
+			if err != nil && !apierrors.IsAlreadyExists(err) {
 				t.Fatalf("failed to create namespace: %v", err)
 			}
 
@@ -110,7 +113,7 @@ func TestOpenShiftNamespace(t *testing.T) {
 			_, err = clientset.CoreV1().ServiceAccounts(tt.namespace.Name).Create(
 				context.TODO(), sa, metav1.CreateOptions{},
 			)
-			if err != nil {
+			if err != nil && !apierrors.IsAlreadyExists(err) {
 				t.Fatalf("failed to create service account: %v", err)
 			}
 
@@ -133,12 +136,12 @@ func TestOpenShiftNamespace(t *testing.T) {
 				},
 			}
 
-			_, err := clientset.RbacV1().RoleBindings(tt.namespace.Name).Create(
+			_, err = clientset.RbacV1().RoleBindings(tt.namespace.Name).Create(
 				context.TODO(),
 				roleBinding,
 				metav1.CreateOptions{},
 			)
-			if err != nil {
+			if err != nil && !apierrors.IsAlreadyExists(err) {
 				t.Fatalf("failed to create role binding: %v", err)
 			}
 
@@ -186,7 +189,7 @@ func TestOpenShiftNamespace(t *testing.T) {
 				deployment,
 				metav1.CreateOptions{},
 			)
-			if err != nil {
+			if err != nil && !apierrors.IsAlreadyExists(err) {
 				t.Fatalf("failed to create deployment: %v", err)
 			}
 
@@ -219,7 +222,7 @@ func TestOpenShiftNamespace(t *testing.T) {
 				matches := re.FindAllString(logs, -1)
 
 				if len(matches) > 0 {
-					filename := fmt.Sprintf("logs_%s_%s.txt", tt.name, time.Now().Format("20060102_150405"))
+					filename := sanitizeFilename(fmt.Sprintf("logs_%s_%s.txt", tt.name, time.Now().Format("20060102_150405")))
 					err = os.WriteFile(filename, buf.Bytes(), 0644)
 					if err != nil {
 						t.Errorf("failed to write logs to file: %v", err)
@@ -238,13 +241,9 @@ func TestOpenShiftNamespace(t *testing.T) {
 }
 
 func clientset() (*kubernetes.Clientset, error) {
-	if kubeconfig := os.Getenv("KUBECONFIG"); kubeconfig != "" {
-		config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-		if err != nil {
-			return nil, err
-		}
-
-		return kubernetes.NewForConfig(config)
+	// KUBECONFIG may list several files, which are merged.
+	if os.Getenv("KUBECONFIG") != "" {
+		return (&cmdutil.Flags{}).Clientset()
 	}
 
 	return nil, fmt.Errorf("KUBECONFIG not set")
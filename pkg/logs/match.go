@@ -0,0 +1,127 @@
+package logs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// matcher matches log lines against a set of patterns. A line matches if
+// any of the patterns matches, or, if inverted, if none of them does.
+type matcher struct {
+	patterns []*regexp.Regexp
+	invert   bool
+
+	// timestamps is set if the lines start with the kubelet's timestamp,
+	// which is then left out of matching and checked against window.
+	timestamps bool
+	window     timeWindow
+
+	// onMatch, if set, is called for every matching line, e.g. to stop
+	// following the logs once the pattern was seen.
+	onMatch func()
+}
+
+// timeWindow selects lines by their timestamp. Zero bounds are open.
+type timeWindow struct {
+	after  time.Time
+	before time.Time
+}
+
+// contains reports whether t lies within the window.
+func (w timeWindow) contains(t time.Time) bool {
+	if !w.after.IsZero() && t.Before(w.after) {
+		return false
+	}
+	if !w.before.IsZero() && t.After(w.before) {
+		return false
+	}
+
+	return true
+}
+
+// newMatcher compiles all patterns up front and fails on the first invalid
+// one. With ignoreCase the patterns are matched case-insensitively, with
+// invert the lines that don't match are reported, like grep's -i and -v.
+func newMatcher(patterns []string, ignoreCase, invert bool) (*matcher, error) {
+	m := &matcher{invert: invert}
+	for _, pattern := range patterns {
+		if ignoreCase {
+			pattern = "(?i)" + pattern
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		m.patterns = append(m.patterns, re)
+	}
+
+	return m, nil
+}
+
+// match reports whether the line matches and returns the pattern that hit.
+// Inverted matches have no pattern that hit.
+func (m *matcher) match(line string) (string, bool) {
+	if m.timestamps {
+		timestamp, message, ok := splitTimestamp(line)
+		if ok {
+			if !m.window.contains(timestamp) {
+				return "", false
+			}
+			line = message
+		}
+	}
+
+	for _, re := range m.patterns {
+		if re.MatchString(line) {
+			if m.invert {
+				return "", false
+			}
+			return re.String(), true
+		}
+	}
+
+	return "", m.invert
+}
+
+// splitTimestamp splits a line of logs requested with timestamps into the
+// kubelet's RFC3339 timestamp and the message.
+func splitTimestamp(line string) (time.Time, string, bool) {
+	field, message, found := strings.Cut(line, " ")
+	if !found {
+		return time.Time{}, line, false
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, field)
+	if err != nil {
+		return time.Time{}, line, false
+	}
+
+	return timestamp, message, true
+}
+
+// readPatternFile reads one pattern per line from the file. Blank lines and
+// lines starting with # are skipped.
+func readPatternFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+
+	return patterns, scanner.Err()
+}
@@ -0,0 +1,150 @@
+package logs
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+const cannedLogs = `I0101 00:00:00.000000       1 controller.go:10] Starting = pod-security-admission-label-synchronization-controller =
+I0101 00:00:01.000000       1 controller.go:20] syncing namespace "test-namespace-1"
+E0101 00:00:02.000000       1 controller.go:30] failed to sync namespace "openshift-test-namespace-2"
+I0101 00:00:03.000000       1 controller.go:40] Shutting down = POD-SECURITY-ADMISSION-LABEL-SYNCHRONIZATION-CONTROLLER =
+`
+
+func TestScanLogs(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		patterns    []string
+		ignoreCase  bool
+		invert      bool
+		wantMatches int
+		wantOutput  string
+	}{
+		{
+			name:        "should match case-sensitively by default",
+			patterns:    []string{defaultPattern},
+			wantMatches: 1,
+			wantOutput:  "ns/pod: I0101 00:00:00.000000       1 controller.go:10] Starting = pod-security-admission-label-synchronization-controller =\n",
+		},
+		{
+			name:        "should match case-insensitively with ignore-case",
+			patterns:    []string{defaultPattern},
+			ignoreCase:  true,
+			wantMatches: 2,
+			wantOutput: "ns/pod: I0101 00:00:00.000000       1 controller.go:10] Starting = pod-security-admission-label-synchronization-controller =\n" +
+				"ns/pod: I0101 00:00:03.000000       1 controller.go:40] Shutting down = POD-SECURITY-ADMISSION-LABEL-SYNCHRONIZATION-CONTROLLER =\n",
+		},
+		{
+			name:        "should report non-matching lines with invert",
+			patterns:    []string{defaultPattern},
+			invert:      true,
+			wantMatches: 3,
+			wantOutput: "ns/pod: I0101 00:00:01.000000       1 controller.go:20] syncing namespace \"test-namespace-1\"\n" +
+				"ns/pod: E0101 00:00:02.000000       1 controller.go:30] failed to sync namespace \"openshift-test-namespace-2\"\n" +
+				"ns/pod: I0101 00:00:03.000000       1 controller.go:40] Shutting down = POD-SECURITY-ADMISSION-LABEL-SYNCHRONIZATION-CONTROLLER =\n",
+		},
+		{
+			name:        "should compose ignore-case and invert",
+			patterns:    []string{defaultPattern},
+			ignoreCase:  true,
+			invert:      true,
+			wantMatches: 2,
+			wantOutput: "ns/pod: I0101 00:00:01.000000       1 controller.go:20] syncing namespace \"test-namespace-1\"\n" +
+				"ns/pod: E0101 00:00:02.000000       1 controller.go:30] failed to sync namespace \"openshift-test-namespace-2\"\n",
+		},
+		{
+			name:        "should report the pattern that hit with several patterns",
+			patterns:    []string{"^E", "Starting"},
+			wantMatches: 2,
+			wantOutput: "ns/pod: [Starting] I0101 00:00:00.000000       1 controller.go:10] Starting = pod-security-admission-label-synchronization-controller =\n" +
+				"ns/pod: [^E] E0101 00:00:02.000000       1 controller.go:30] failed to sync namespace \"openshift-test-namespace-2\"\n",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := newMatcher(tt.patterns, tt.ignoreCase, tt.invert)
+			if err != nil {
+				t.Fatalf("failed to create matcher: %v", err)
+			}
+
+			var out bytes.Buffer
+			matches, err := scanLogs(strings.NewReader(cannedLogs), m, 0, &out, "ns/pod")
+			if err != nil {
+				t.Fatalf("failed to scan logs: %v", err)
+			}
+
+			if matches != tt.wantMatches {
+				t.Errorf("expected %d matches, got %d", tt.wantMatches, matches)
+			}
+			if out.String() != tt.wantOutput {
+				t.Errorf("unexpected output:\n%s\nwant:\n%s", out.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestNewMatcherInvalidPattern(t *testing.T) {
+	if _, err := newMatcher([]string{"valid", "(invalid"}, false, false); err == nil {
+		t.Errorf("expected an error for an invalid pattern")
+	}
+}
+
+func TestMatcherTimeWindow(t *testing.T) {
+	const timestampedLogs = `2024-06-01T11:59:59.000000000Z syncing namespace "early"
+2024-06-01T12:00:00.000000000Z syncing namespace "inside"
+2024-06-01T12:30:00.500000000Z syncing namespace "also-inside"
+2024-06-01T13:00:01.000000000Z syncing namespace "late"
+`
+
+	for _, tt := range []struct {
+		name        string
+		window      timeWindow
+		wantMatches int
+	}{
+		{
+			name:        "should match every line without bounds",
+			wantMatches: 4,
+		},
+		{
+			name:        "should skip lines before after",
+			window:      timeWindow{after: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)},
+			wantMatches: 3,
+		},
+		{
+			name: "should only match lines within after and before",
+			window: timeWindow{
+				after:  time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+				before: time.Date(2024, 6, 1, 13, 0, 0, 0, time.UTC),
+			},
+			wantMatches: 2,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// The pattern is anchored to the message, so it only matches if
+			// the timestamp is split off.
+			m, err := newMatcher([]string{"^syncing"}, false, false)
+			if err != nil {
+				t.Fatalf("failed to create matcher: %v", err)
+			}
+			m.timestamps = true
+			m.window = tt.window
+
+			matches, err := scanLogs(strings.NewReader(timestampedLogs), m, 0, io.Discard, "ns/pod")
+			if err != nil {
+				t.Fatalf("failed to scan logs: %v", err)
+			}
+
+			if matches != tt.wantMatches {
+				t.Errorf("expected %d matches, got %d", tt.wantMatches, matches)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+// Ways of waiting for a created pod to run.
+const (
+	readinessWatch = "watch"
+	readinessPoll  = "poll"
+)
+
+// readiness configures how long and how a created pod is waited for.
+type readiness struct {
+	mode string
+	// interval is how often the pod is polled, it doesn't apply to watch.
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// validate checks the mode and that the durations are positive.
+func (r readiness) validate() error {
+	if r.mode != readinessWatch && r.mode != readinessPoll {
+		return fmt.Errorf("unknown --readiness-mode %q, must be watch or poll", r.mode)
+	}
+	if r.interval <= 0 || r.timeout <= 0 {
+		return fmt.Errorf("--readiness-interval and --readiness-timeout must be positive")
+	}
+
+	return nil
+}
+
+// waitForPodRunning waits until the pod runs, the timeout passes or ctx is
+// done. Watching notices the pod running right away, polling is the fallback
+// for clusters behind proxies that break watches.
+func waitForPodRunning(ctx context.Context, clientset kubernetes.Interface, namespace, name string, r readiness) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if r.mode == readinessPoll {
+		return wait.PollUntilContextCancel(ctx, r.interval, true, func(ctx context.Context) (bool, error) {
+			pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			return pod.Status.Phase == corev1.PodRunning, nil
+		})
+	}
+
+	fieldSelector := fields.OneTermEqualSelector("metadata.name", name).String()
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = fieldSelector
+			return clientset.CoreV1().Pods(namespace).List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = fieldSelector
+			return clientset.CoreV1().Pods(namespace).Watch(ctx, options)
+		},
+	}
+	_, err := watchtools.UntilWithSync(ctx, lw, &corev1.Pod{}, nil, func(event watch.Event) (bool, error) {
+		pod, ok := event.Object.(*corev1.Pod)
+		return ok && pod.Name == name && pod.Status.Phase == corev1.PodRunning, nil
+	})
+
+	return err
+}
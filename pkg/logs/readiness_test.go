@@ -0,0 +1,67 @@
+package logs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWaitForPodRunning(t *testing.T) {
+	pod := func(phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "ns"},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+
+	for _, tt := range []struct {
+		name    string
+		mode    string
+		runs    bool
+		wantErr bool
+	}{
+		{
+			name: "should return once a watched pod runs",
+			mode: readinessWatch,
+			runs: true,
+		},
+		{
+			name: "should return once a polled pod runs",
+			mode: readinessPoll,
+			runs: true,
+		},
+		{
+			name:    "should time out watching a pod that doesn't run",
+			mode:    readinessWatch,
+			wantErr: true,
+		},
+		{
+			name:    "should time out polling a pod that doesn't run",
+			mode:    readinessPoll,
+			wantErr: true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			clientset := fake.NewSimpleClientset(pod(corev1.PodPending))
+			if tt.runs {
+				go func() {
+					time.Sleep(50 * time.Millisecond)
+					_, _ = clientset.CoreV1().Pods("ns").UpdateStatus(context.Background(), pod(corev1.PodRunning), metav1.UpdateOptions{})
+				}()
+			}
+
+			r := readiness{mode: tt.mode, interval: 10 * time.Millisecond, timeout: 500 * time.Millisecond}
+			err := waitForPodRunning(context.Background(), clientset, "ns", "test-pod", r)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
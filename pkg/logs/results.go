@@ -0,0 +1,82 @@
+package logs
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// MatchResult is the outcome of searching the logs of a container, as
+// printed with --output json.
+type MatchResult struct {
+	Namespace  string `json:"namespace"`
+	Pod        string `json:"pod"`
+	Container  string `json:"container"`
+	MatchCount int    `json:"matchCount"`
+	// SavedFile is the file the logs were saved to, or with --archive the
+	// name of their entry in Archive. Both are empty with --count-only.
+	SavedFile string `json:"savedFile,omitempty"`
+	Archive   string `json:"archive,omitempty"`
+	// Truncated is set if the logs were cut off at --max-bytes.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// matchResults collects the results of all searched containers. It is safe
+// for concurrent use. A nil *matchResults records nothing.
+type matchResults struct {
+	archive string
+	results []MatchResult
+
+	mu sync.Mutex
+}
+
+// newMatchResults starts collecting the results of a search run.
+func newMatchResults(archive string) *matchResults {
+	return &matchResults{archive: archive, results: []MatchResult{}}
+}
+
+// add records the result of the container, with file being where its logs
+// were saved, if anywhere.
+func (r *matchResults) add(namespace, pod, container, file string, matches int, truncated bool) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := MatchResult{Namespace: namespace, Pod: pod, Container: container, MatchCount: matches, SavedFile: file, Truncated: truncated}
+	if file != "" {
+		result.Archive = r.archive
+	}
+	r.results = append(r.results, result)
+}
+
+// write writes the results as a JSON array, sorted by namespace, pod and
+// container so that runs are comparable.
+func (r *matchResults) write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sort.SliceStable(r.results, func(i, j int) bool {
+		a, b := r.results[i], r.results[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Pod != b.Pod {
+			return a.Pod < b.Pod
+		}
+		return a.Container < b.Container
+	})
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(r.results)
+}
@@ -0,0 +1,241 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakeLogFetcher returns canned logs per container, after failing with the
+// queued errors. The logs of a previous instance are keyed "name[previous]".
+type fakeLogFetcher struct {
+	mu     sync.Mutex
+	logs   map[string]string
+	errors []error
+}
+
+func (f *fakeLogFetcher) Stream(_ context.Context, _, _ string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.errors) > 0 {
+		err := f.errors[0]
+		f.errors = f.errors[1:]
+		return nil, err
+	}
+
+	key := opts.Container
+	if opts.Previous {
+		key += "[previous]"
+	}
+
+	logs, ok := f.logs[key]
+	if !ok {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "pods/log"}, key)
+	}
+
+	return io.NopCloser(strings.NewReader(logs)), nil
+}
+
+func TestSearchPodLogs(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		fetcher      *fakeLogFetcher
+		countOnly    bool
+		contextLines int
+		maxBytes     int64
+		wantMatches  int
+		wantOutput   string
+		wantFiles    int
+	}{
+		{
+			name:        "should count the matches of every container",
+			fetcher:     &fakeLogFetcher{logs: map[string]string{"app": cannedLogs, "sidecar": cannedLogs}},
+			countOnly:   true,
+			wantMatches: 2,
+			wantOutput:  "ns/pod/app: 1\nns/pod/sidecar: 1\n",
+		},
+		{
+			name:         "should print context lines and save the logs with matches",
+			fetcher:      &fakeLogFetcher{logs: map[string]string{"app": cannedLogs, "sidecar": "nothing to see\n"}},
+			contextLines: 1,
+			wantMatches:  1,
+			wantOutput: "ns/pod/app: I0101 00:00:00.000000       1 controller.go:10] Starting = pod-security-admission-label-synchronization-controller =\n" +
+				"ns/pod/app- I0101 00:00:01.000000       1 controller.go:20] syncing namespace \"test-namespace-1\"\n",
+			wantFiles: 1,
+		},
+		{
+			name:    "should skip a container whose log stream can't be opened",
+			fetcher: &fakeLogFetcher{logs: map[string]string{"app": cannedLogs}},
+			// The sidecar has no logs, which fails with NotFound.
+			wantMatches: 1,
+			wantOutput:  "ns/pod/app: I0101 00:00:00.000000       1 controller.go:10] Starting = pod-security-admission-label-synchronization-controller =\n",
+			wantFiles:   1,
+		},
+		{
+			name: "should retry a transient error opening the log stream",
+			fetcher: &fakeLogFetcher{
+				logs:   map[string]string{"app": cannedLogs, "sidecar": ""},
+				errors: []error{apierrors.NewServiceUnavailable("try again")},
+			},
+			countOnly:   true,
+			wantMatches: 1,
+			wantOutput:  "ns/pod/app: 1\nns/pod/sidecar: 0\n",
+		},
+		{
+			name: "should give up on a non-transient error opening the log stream",
+			fetcher: &fakeLogFetcher{
+				logs:   map[string]string{"sidecar": ""},
+				errors: []error{apierrors.NewForbidden(schema.GroupResource{Resource: "pods/log"}, "pod", errors.New("denied"))},
+			},
+			countOnly:  true,
+			wantOutput: "ns/pod/sidecar: 0\n",
+		},
+		{
+			name:       "should only search the logs up to --max-bytes",
+			fetcher:    &fakeLogFetcher{logs: map[string]string{"app": "filler\n" + cannedLogs, "sidecar": ""}},
+			countOnly:  true,
+			maxBytes:   int64(len("filler\n")),
+			wantOutput: "ns/pod/app: 0 (truncated)\nns/pod/sidecar: 0\n",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := newMatcher([]string{defaultPattern}, false, false)
+			if err != nil {
+				t.Fatalf("failed to create matcher: %v", err)
+			}
+
+			var out bytes.Buffer
+			dir := t.TempDir()
+			opts := searchOptions{
+				matcher:      m,
+				contextLines: tt.contextLines,
+				countOnly:    tt.countOnly,
+				maxBytes:     tt.maxBytes,
+				retries:      1,
+				out:          &out,
+				dir:          dir,
+				log:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+
+			matches := searchPodLogs(context.Background(), tt.fetcher, runningPod("ns", "pod", "app", "sidecar"), opts)
+
+			if matches != tt.wantMatches {
+				t.Errorf("expected %d matches, got %d", tt.wantMatches, matches)
+			}
+			if out.String() != tt.wantOutput {
+				t.Errorf("unexpected output:\n%s\nwant:\n%s", out.String(), tt.wantOutput)
+			}
+
+			files, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("failed to read dir: %v", err)
+			}
+			if len(files) != tt.wantFiles {
+				t.Errorf("expected %d saved files, got %d", tt.wantFiles, len(files))
+			}
+		})
+	}
+}
+
+func TestSearchPodLogsOfRestartedContainer(t *testing.T) {
+	m, err := newMatcher([]string{defaultPattern}, false, false)
+	if err != nil {
+		t.Fatalf("failed to create matcher: %v", err)
+	}
+
+	pod := runningPod("ns", "pod", "app")
+	pod.Status.ContainerStatuses[0].RestartCount = 1
+	pod.Status.ContainerStatuses[0].LastTerminationState.Terminated = &corev1.ContainerStateTerminated{ExitCode: 1}
+
+	fetcher := &fakeLogFetcher{logs: map[string]string{"app[previous]": cannedLogs, "app": "nothing to see\n"}}
+
+	var out bytes.Buffer
+	opts := searchOptions{
+		matcher:   m,
+		countOnly: true,
+		retries:   1,
+		out:       &out,
+		dir:       t.TempDir(),
+		log:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	if matches := searchPodLogs(context.Background(), fetcher, pod, opts); matches != 1 {
+		t.Errorf("expected 1 match, got %d", matches)
+	}
+	if want := "ns/pod/app[previous]: 1\nns/pod/app[current]: 0\n"; out.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestSearchPodLogsRecordsResults(t *testing.T) {
+	m, err := newMatcher([]string{defaultPattern}, false, false)
+	if err != nil {
+		t.Fatalf("failed to create matcher: %v", err)
+	}
+
+	fetcher := &fakeLogFetcher{logs: map[string]string{"app": "nothing to see\n", "sidecar": cannedLogs}}
+	dir := t.TempDir()
+	results := newMatchResults("")
+	opts := searchOptions{
+		matcher: m,
+		retries: 1,
+		results: results,
+		out:     io.Discard,
+		dir:     dir,
+		log:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	searchPodLogs(context.Background(), fetcher, runningPod("ns", "pod", "app", "sidecar"), opts)
+
+	var out bytes.Buffer
+	if err := results.write(&out); err != nil {
+		t.Fatalf("failed to write results: %v", err)
+	}
+	var got []MatchResult
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("failed to decode results: %v\n%s", err, out.String())
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected 1 result, got %+v", got)
+	}
+	if got[0].Namespace != "ns" || got[0].Pod != "pod" || got[0].Container != "sidecar" || got[0].MatchCount != 1 {
+		t.Errorf("unexpected result %+v", got[0])
+	}
+	if filepath.Dir(got[0].SavedFile) != dir {
+		t.Errorf("expected the saved file in %s, got %q", dir, got[0].SavedFile)
+	}
+}
+
+// runningPod returns a pod whose containers are all running.
+func runningPod(namespace, name string, containers ...string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	for _, container := range containers {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: container})
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+			Name:  container,
+			State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+		})
+	}
+
+	return pod
+}
@@ -0,0 +1,104 @@
+package namespaceapply
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	applyconfigurationsv1 "k8s.io/client-go/applyconfigurations/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Operations of a FieldChange.
+const (
+	fieldAdded   = "add"
+	fieldRemoved = "remove"
+	fieldChanged = "change"
+)
+
+// FieldChange is a single label or annotation that differs between two
+// apply configurations.
+type FieldChange struct {
+	Field     string
+	Key       string
+	Operation string
+	From      string
+	To        string
+}
+
+func (c FieldChange) String() string {
+	switch c.Operation {
+	case fieldAdded:
+		return fmt.Sprintf("%s %s: add %q", c.Field, c.Key, c.To)
+	case fieldRemoved:
+		return fmt.Sprintf("%s %s: remove %q", c.Field, c.Key, c.From)
+	default:
+		return fmt.Sprintf("%s %s: change %q to %q", c.Field, c.Key, c.From, c.To)
+	}
+}
+
+// diffApplyConfig reports the labels and annotations that applying desired
+// adds, removes or changes compared to what the field manager currently owns.
+// As the field manager owns exactly the fields it applies, fields missing
+// from desired are removed.
+func diffApplyConfig(current, desired *applyconfigurationsv1.NamespaceApplyConfiguration) []FieldChange {
+	var changes []FieldChange
+	changes = append(changes, diffMap("label", current.Labels, desired.Labels)...)
+	changes = append(changes, diffMap("annotation", current.Annotations, desired.Annotations)...)
+
+	return changes
+}
+
+// diffMap compares the keys of current and desired, sorted by key.
+func diffMap(field string, current, desired map[string]string) []FieldChange {
+	keys := map[string]struct{}{}
+	for k := range current {
+		keys[k] = struct{}{}
+	}
+	for k := range desired {
+		keys[k] = struct{}{}
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var changes []FieldChange
+	for _, k := range sorted {
+		from, inCurrent := current[k]
+		to, inDesired := desired[k]
+
+		switch {
+		case inCurrent && !inDesired:
+			changes = append(changes, FieldChange{Field: field, Key: k, Operation: fieldRemoved, From: from})
+		case !inCurrent && inDesired:
+			changes = append(changes, FieldChange{Field: field, Key: k, Operation: fieldAdded, To: to})
+		case from != to:
+			changes = append(changes, FieldChange{Field: field, Key: k, Operation: fieldChanged, From: from, To: to})
+		}
+	}
+
+	return changes
+}
+
+func printApplyDiff(ctx context.Context, clientset kubernetes.Interface, desired *applyconfigurationsv1.NamespaceApplyConfiguration, fieldManager string) error {
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, *desired.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("Error getting namespace: %w", err)
+	}
+
+	current, err := applyconfigurationsv1.ExtractNamespace(ns, fieldManager)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("---\nChanges %s is about to apply to %s:\n", fieldManager, *desired.Name)
+	for _, change := range diffApplyConfig(current, desired) {
+		fmt.Printf("- %s\n", change)
+	}
+
+	return nil
+}
@@ -0,0 +1,326 @@
+package namespaceapply
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	applyconfigurationsv1 "k8s.io/client-go/applyconfigurations/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/yaml"
+
+	"github.com/ibihim/kube-plays/pkg/cmdutil"
+)
+
+const (
+	// ownerName is the default field manager of the demo's applies.
+	ownerName string = "ibihim"
+)
+
+type options struct {
+	showOwners    string
+	scanConflicts bool
+	scanManagers  bool
+	serverDryRun  bool
+	fieldManager  string
+	namespace     string
+	showExtracted bool
+}
+
+// NewCommand returns the namespace-apply command, which demonstrates how
+// server-side apply and the extraction of apply configurations behave on
+// namespace labels.
+func NewCommand(flags *cmdutil.Flags) *cobra.Command {
+	opts := &options{}
+
+	cmd := &cobra.Command{
+		Use:   "namespace-apply",
+		Short: "Demonstrate server-side apply of namespace labels",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd.Context(), flags, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.showOwners, "show-owners", "", "Only print which field managers own the labels of this namespace")
+	cmd.Flags().BoolVar(&opts.scanConflicts, "scan-conflicts", false, "Only list the PodSecurity labels across all namespaces that several field managers own")
+	cmd.Flags().BoolVar(&opts.scanManagers, "scan-managers", false, "Only count the field managers owning PodSecurity labels across all namespaces")
+	cmd.Flags().BoolVar(&opts.serverDryRun, "server-dry-run", false, "Dry-run the applies on the server and print the labels and annotations they would produce")
+	cmd.Flags().StringVarP(&opts.namespace, "namespace", "n", "", "Run the demo in this namespace, reusing and keeping it if it exists (default test-namespace-<timestamp>)")
+	cmd.Flags().BoolVar(&opts.showExtracted, "show-extracted", false, "Also print the whole apply configuration extracted for the field manager as YAML")
+	cmd.Flags().StringVar(&opts.fieldManager, "field-manager", ownerName, "Field manager of the applies, run twice with different managers to have them contend over the labels")
+
+	return cmd
+}
+
+func run(ctx context.Context, flags *cmdutil.Flags, opts *options) error {
+	clientset, err := flags.Clientset()
+	if err != nil {
+		return fmt.Errorf("Error creating clientset: %w", err)
+	}
+
+	if opts.showOwners != "" {
+		return printLabelOwners(ctx, clientset, opts.showOwners)
+	}
+
+	if opts.scanConflicts {
+		return printLabelConflicts(ctx, clientset)
+	}
+
+	if opts.scanManagers {
+		return printLabelManagers(ctx, clientset)
+	}
+
+	nsName := opts.namespace
+	if nsName == "" {
+		nsName = "test-namespace-" + time.Now().Format("20060102-150405")
+	}
+
+	created, err := createNamespace(ctx, clientset, nsName)
+	if err != nil {
+		return err
+	}
+
+	if err := printNamespaceLabels(ctx, clientset, nsName); err != nil {
+		return err
+	}
+
+	if err := applyConfiguration(ctx, clientset, nsName, opts.fieldManager, opts.serverDryRun); err != nil {
+		return err
+	}
+
+	if err := printNamespaceLabels(ctx, clientset, nsName); err != nil {
+		return err
+	}
+
+	if err := applyConfigurationLabelCheck(ctx, clientset, nsName, opts.fieldManager, opts.showExtracted); err != nil {
+		return err
+	}
+
+	if err := printLabelOwners(ctx, clientset, nsName); err != nil {
+		return err
+	}
+
+	if err := printNamespaceAnnotations(ctx, clientset, nsName); err != nil {
+		return err
+	}
+
+	if err := applyAnnotations(ctx, clientset, nsName, map[string]string{
+		"my-annotation": "applied",
+	}, opts.fieldManager, opts.serverDryRun); err != nil {
+		return err
+	}
+
+	if err := printNamespaceAnnotations(ctx, clientset, nsName); err != nil {
+		return err
+	}
+
+	if err := applyConfigurationAnnotationCheck(ctx, clientset, nsName, opts.fieldManager); err != nil {
+		return err
+	}
+
+	// A namespace that existed before is left for the next run.
+	if !created {
+		return nil
+	}
+
+	if err := cleanUp(ctx, clientset, nsName); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cleanUp(ctx context.Context, clientset kubernetes.Interface, nsName string) error {
+	err := clientset.CoreV1().Namespaces().Delete(ctx, nsName, metav1.DeleteOptions{})
+	if err != nil {
+		return fmt.Errorf("Error deleting namespace: %w", err)
+	}
+
+	return nil
+}
+
+func applyConfigurationLabelCheck(ctx context.Context, clientset kubernetes.Interface, nsName, fieldManager string, showExtracted bool) error {
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, nsName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("Error getting namespace: %w", err)
+	}
+
+	nsApplyConfig, err := applyconfigurationsv1.ExtractNamespace(ns, fieldManager)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("---")
+	fmt.Println("Labels from", nsName)
+	for k, v := range nsApplyConfig.Labels {
+		fmt.Printf("- %s: %s\n", k, v)
+	}
+
+	if showExtracted {
+		return printExtracted(nsApplyConfig, fieldManager)
+	}
+
+	return nil
+}
+
+// printExtracted prints the apply configuration as YAML, which shows exactly
+// which fields ExtractNamespace considers owned by the field manager.
+func printExtracted(nsApplyConfig *applyconfigurationsv1.NamespaceApplyConfiguration, fieldManager string) error {
+	data, err := yaml.Marshal(nsApplyConfig)
+	if err != nil {
+		return fmt.Errorf("Error marshalling extracted configuration: %w", err)
+	}
+
+	fmt.Printf("---\n# Apply configuration extracted for %s\n%s", fieldManager, data)
+
+	return nil
+}
+
+func applyConfiguration(ctx context.Context, clientset kubernetes.Interface, nsName, fieldManager string, dryRun bool) error {
+	nsApply := applyconfigurationsv1.Namespace(nsName).WithLabels(map[string]string{
+		"my-enforce": "restricted",
+	})
+
+	if err := printApplyDiff(ctx, clientset, nsApply, fieldManager); err != nil {
+		return err
+	}
+
+	ns, err := clientset.CoreV1().Namespaces().Apply(ctx, nsApply, applyOptions(fieldManager, dryRun))
+	if err != nil {
+		return fmt.Errorf("Error applying configuration: %w", err)
+	}
+
+	if dryRun {
+		fmt.Printf("---\nLabels the server would produce for namespace %s:\n", nsName)
+		for k, v := range ns.Labels {
+			fmt.Printf("- %s: %s\n", k, v)
+		}
+	}
+
+	return nil
+}
+
+func applyAnnotations(ctx context.Context, clientset kubernetes.Interface, nsName string, annotations map[string]string, fieldManager string, dryRun bool) error {
+	nsApply := applyconfigurationsv1.Namespace(nsName).WithAnnotations(annotations)
+
+	if err := printApplyDiff(ctx, clientset, nsApply, fieldManager); err != nil {
+		return err
+	}
+
+	ns, err := clientset.CoreV1().Namespaces().Apply(ctx, nsApply, applyOptions(fieldManager, dryRun))
+	if err != nil {
+		return fmt.Errorf("Error applying annotations: %w", err)
+	}
+
+	if dryRun {
+		fmt.Printf("---\nAnnotations the server would produce for namespace %s:\n", nsName)
+		for k, v := range ns.Annotations {
+			fmt.Printf("- %s: %s\n", k, v)
+		}
+	}
+
+	return nil
+}
+
+// applyOptions returns the options of the demo's applies. A server-side dry
+// run goes through defaulting and admission like a real apply, but doesn't
+// persist anything.
+func applyOptions(fieldManager string, dryRun bool) metav1.ApplyOptions {
+	opts := metav1.ApplyOptions{
+		FieldManager: fieldManager,
+	}
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+
+	return opts
+}
+
+func applyConfigurationAnnotationCheck(ctx context.Context, clientset kubernetes.Interface, nsName, fieldManager string) error {
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, nsName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("Error getting namespace: %w", err)
+	}
+
+	nsApplyConfig, err := applyconfigurationsv1.ExtractNamespace(ns, fieldManager)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("---")
+	fmt.Println("Annotations from", nsName)
+	for k, v := range nsApplyConfig.Annotations {
+		fmt.Printf("- %s: %s\n", k, v)
+	}
+
+	return nil
+}
+
+func printNamespaceAnnotations(ctx context.Context, clientset kubernetes.Interface, nsName string) error {
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, nsName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("Error getting namespace: %w", err)
+	}
+
+	fmt.Printf("---\nAnnotations for namespace %s:\n", nsName)
+
+	for k, v := range ns.Annotations {
+		fmt.Printf("- %s: %s\n", k, v)
+	}
+
+	return nil
+}
+
+func printNamespaceLabels(ctx context.Context, clientset kubernetes.Interface, nsName string) error {
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, nsName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("Error getting namespace: %w", err)
+	}
+
+	fmt.Printf("---\nLabels for namespace %s:\n", nsName)
+
+	for k, v := range ns.Labels {
+		fmt.Printf("- %s: %s\n", k, v)
+	}
+
+	return nil
+}
+
+// createNamespace creates the namespace and reports whether it did, an
+// existing namespace is used as it is.
+func createNamespace(ctx context.Context, clientset kubernetes.Interface, nsName string) (bool, error) {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: nsName,
+			Labels: map[string]string{
+				"foo": "bar",
+			},
+		},
+	}
+
+	_, err := clientset.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		fmt.Printf("---\nNamespace %s already exists, reusing it\n", nsName)
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("Error creating namespace: %w", err)
+	}
+
+	// Wait for the namespace to be fully created
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		_, err := clientset.CoreV1().Namespaces().Get(ctx, nsName, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("Error waiting for namespace to be created: %w", err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,29 @@
+package namespaceapply
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCreateNamespace(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset()
+
+	created, err := createNamespace(ctx, clientset, "test-namespace")
+	if err != nil {
+		t.Fatalf("failed to create namespace: %v", err)
+	}
+	if !created {
+		t.Errorf("expected the namespace to be created")
+	}
+
+	created, err = createNamespace(ctx, clientset, "test-namespace")
+	if err != nil {
+		t.Fatalf("failed to reuse namespace: %v", err)
+	}
+	if created {
+		t.Errorf("expected the existing namespace to be reused")
+	}
+}
@@ -0,0 +1,260 @@
+package namespaceapply
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// podSecurityLabelPrefix is the prefix of the PodSecurity admission labels.
+const podSecurityLabelPrefix = "pod-security.kubernetes.io/"
+
+// labelOwner is a field manager that owns a label.
+type labelOwner struct {
+	Manager   string
+	Operation metav1.ManagedFieldsOperationType
+}
+
+// labelOwners maps every label of the namespace to the field managers that
+// own it, according to the namespace's managed fields. Labels owned by
+// nobody, e.g. set before managed fields were tracked, map to no owners.
+func labelOwners(ns *corev1.Namespace) (map[string][]labelOwner, error) {
+	owners := map[string][]labelOwner{}
+	for key := range ns.Labels {
+		owners[key] = nil
+	}
+
+	for _, entry := range ns.ManagedFields {
+		if entry.FieldsV1 == nil {
+			continue
+		}
+
+		keys, err := ownedLabels(entry.FieldsV1.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing managed fields of %s: %w", entry.Manager, err)
+		}
+
+		for _, key := range keys {
+			owners[key] = append(owners[key], labelOwner{
+				Manager:   entry.Manager,
+				Operation: entry.Operation,
+			})
+		}
+	}
+
+	return owners, nil
+}
+
+// ownedLabels returns the label keys in a FieldsV1 set, which lists owned
+// labels as {"f:metadata": {"f:labels": {"f:<key>": {}}}}.
+func ownedLabels(raw []byte) ([]string, error) {
+	var fields struct {
+		Metadata struct {
+			Labels map[string]json.RawMessage `json:"f:labels"`
+		} `json:"f:metadata"`
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for field := range fields.Metadata.Labels {
+		if key, ok := strings.CutPrefix(field, "f:"); ok {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+func printLabelOwners(ctx context.Context, clientset kubernetes.Interface, nsName string) error {
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, nsName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("Error getting namespace: %w", err)
+	}
+
+	owners, err := labelOwners(ns)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(owners))
+	for key := range owners {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Printf("---\nLabel owners for namespace %s:\n", nsName)
+	for _, key := range keys {
+		if len(owners[key]) == 0 {
+			fmt.Printf("- %s=%s: (no owner)\n", key, ns.Labels[key])
+			continue
+		}
+
+		managers := make([]string, 0, len(owners[key]))
+		for _, owner := range owners[key] {
+			managers = append(managers, fmt.Sprintf("%s (%s)", owner.Manager, owner.Operation))
+		}
+		fmt.Printf("- %s=%s: %s\n", key, ns.Labels[key], strings.Join(managers, ", "))
+	}
+
+	return nil
+}
+
+// labelConflict is a PodSecurity label that is owned by several field
+// managers.
+type labelConflict struct {
+	Namespace string
+	Label     string
+	Owners    []labelOwner
+}
+
+// podSecurityLabelConflicts returns the PodSecurity labels of the namespace
+// that more than one field manager owns, sorted by label.
+func podSecurityLabelConflicts(ns *corev1.Namespace) ([]labelConflict, error) {
+	owners, err := labelOwners(ns)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []labelConflict
+	for key, keyOwners := range owners {
+		if !strings.HasPrefix(key, podSecurityLabelPrefix) || len(keyOwners) < 2 {
+			continue
+		}
+
+		conflicts = append(conflicts, labelConflict{
+			Namespace: ns.Name,
+			Label:     key,
+			Owners:    keyOwners,
+		})
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Label < conflicts[j].Label })
+
+	return conflicts, nil
+}
+
+func printLabelConflicts(ctx context.Context, clientset kubernetes.Interface) error {
+	namespaces, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("Error listing namespaces: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tLABEL\tMANAGERS")
+
+	var count int
+	for i := range namespaces.Items {
+		conflicts, err := podSecurityLabelConflicts(&namespaces.Items[i])
+		if err != nil {
+			return err
+		}
+
+		for _, conflict := range conflicts {
+			managers := make([]string, 0, len(conflict.Owners))
+			for _, owner := range conflict.Owners {
+				managers = append(managers, fmt.Sprintf("%s (%s)", owner.Manager, owner.Operation))
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", conflict.Namespace, conflict.Label, strings.Join(managers, ", "))
+			count++
+		}
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d PodSecurity labels owned by several field managers\n", count)
+
+	return nil
+}
+
+// managerCount counts the PodSecurity labels a field manager owns and the
+// namespaces they are in.
+type managerCount struct {
+	Manager    string
+	Namespaces int
+	Labels     int
+}
+
+// podSecurityLabelManagers counts for every field manager, with its
+// operation, the PodSecurity labels it owns across the namespaces. They are
+// sorted by the number of namespaces, most first. Labels that nobody owns
+// are counted as "(no owner)".
+func podSecurityLabelManagers(namespaces []corev1.Namespace) ([]managerCount, error) {
+	counts := map[string]*managerCount{}
+	count := func(manager string, seen map[string]bool) {
+		if counts[manager] == nil {
+			counts[manager] = &managerCount{Manager: manager}
+		}
+		counts[manager].Labels++
+		if !seen[manager] {
+			seen[manager] = true
+			counts[manager].Namespaces++
+		}
+	}
+
+	for i := range namespaces {
+		owners, err := labelOwners(&namespaces[i])
+		if err != nil {
+			return nil, err
+		}
+
+		seen := map[string]bool{}
+		for key, keyOwners := range owners {
+			if !strings.HasPrefix(key, podSecurityLabelPrefix) {
+				continue
+			}
+			if len(keyOwners) == 0 {
+				count("(no owner)", seen)
+			}
+			for _, owner := range keyOwners {
+				count(fmt.Sprintf("%s (%s)", owner.Manager, owner.Operation), seen)
+			}
+		}
+	}
+
+	managers := make([]managerCount, 0, len(counts))
+	for _, c := range counts {
+		managers = append(managers, *c)
+	}
+	sort.Slice(managers, func(i, j int) bool {
+		if managers[i].Namespaces != managers[j].Namespaces {
+			return managers[i].Namespaces > managers[j].Namespaces
+		}
+		return managers[i].Manager < managers[j].Manager
+	})
+
+	return managers, nil
+}
+
+func printLabelManagers(ctx context.Context, clientset kubernetes.Interface) error {
+	namespaces, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("Error listing namespaces: %w", err)
+	}
+
+	managers, err := podSecurityLabelManagers(namespaces.Items)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "MANAGER\tNAMESPACES\tLABELS")
+	for _, manager := range managers {
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", manager.Manager, manager.Namespaces, manager.Labels)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d field managers own PodSecurity labels across %d namespaces scanned\n", len(managers), len(namespaces.Items))
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package namespaceapply
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodSecurityLabelManagers(t *testing.T) {
+	managedLabels := func(manager string, operation metav1.ManagedFieldsOperationType, keys ...string) metav1.ManagedFieldsEntry {
+		raw := `{"f:metadata":{"f:labels":{`
+		for i, key := range keys {
+			if i > 0 {
+				raw += ","
+			}
+			raw += `"f:` + key + `":{}`
+		}
+		raw += `}}}`
+
+		return metav1.ManagedFieldsEntry{Manager: manager, Operation: operation, FieldsV1: &metav1.FieldsV1{Raw: []byte(raw)}}
+	}
+
+	namespaces := []corev1.Namespace{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "synced",
+				Labels: map[string]string{"pod-security.kubernetes.io/warn": "restricted", "pod-security.kubernetes.io/audit": "restricted", "team": "a"},
+				ManagedFields: []metav1.ManagedFieldsEntry{
+					managedLabels("pod-security-admission-label-synchronization-controller", metav1.ManagedFieldsOperationApply, "pod-security.kubernetes.io/warn", "pod-security.kubernetes.io/audit"),
+					managedLabels("kubectl-edit", metav1.ManagedFieldsOperationUpdate, "team"),
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "edited",
+				Labels: map[string]string{"pod-security.kubernetes.io/enforce": "baseline", "pod-security.kubernetes.io/warn": "restricted"},
+				ManagedFields: []metav1.ManagedFieldsEntry{
+					managedLabels("kubectl-edit", metav1.ManagedFieldsOperationUpdate, "pod-security.kubernetes.io/enforce"),
+				},
+			},
+		},
+	}
+
+	got, err := podSecurityLabelManagers(namespaces)
+	if err != nil {
+		t.Fatalf("failed to count managers: %v", err)
+	}
+
+	want := []managerCount{
+		{Manager: "(no owner)", Namespaces: 1, Labels: 1},
+		{Manager: "kubectl-edit (Update)", Namespaces: 1, Labels: 1},
+		{Manager: "pod-security-admission-label-synchronization-controller (Apply)", Namespaces: 1, Labels: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
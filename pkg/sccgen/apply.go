@@ -0,0 +1,202 @@
+package sccgen
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+
+	"github.com/ibihim/kube-plays/pkg/cmdutil"
+)
+
+// fieldManager is the field manager of the applied manifests.
+const fieldManager = "kube-plays-gen-scc"
+
+// sccKind is the kind of the manifests checked by --verify.
+const sccKind = "SecurityContextConstraints"
+
+// Dry-run modes of --dry-run.
+const (
+	dryRunNone   = "none"
+	dryRunServer = "server"
+)
+
+// applier server-side applies rendered manifests.
+type applier struct {
+	client dynamic.Interface
+	mapper meta.RESTMapper
+	dryRun bool
+	log    *slog.Logger
+}
+
+// newApplier creates an applier for the cluster of flags.
+func newApplier(flags *cmdutil.Flags, dryRun bool) (*applier, error) {
+	config, err := flags.RESTConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &applier{
+		client: client,
+		mapper: restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)),
+		dryRun: dryRun,
+		log:    flags.Logger(),
+	}, nil
+}
+
+// applyFile applies every manifest in the YAML file at path, in order.
+func (a *applier) applyFile(ctx context.Context, path string) error {
+	return forEachManifest(path, func(obj *unstructured.Unstructured) error {
+		if err := a.apply(ctx, obj); err != nil {
+			return fmt.Errorf("error applying %s %q from %s: %w", obj.GetKind(), obj.GetName(), path, err)
+		}
+
+		return nil
+	})
+}
+
+// verifyFile re-reads every SCC of the YAML file at path from the cluster
+// and returns the number of SCCs that drifted from the file, logging the
+// drifted fields.
+func (a *applier) verifyFile(ctx context.Context, path string) (int, error) {
+	var drifted int
+	err := forEachManifest(path, func(obj *unstructured.Unstructured) error {
+		if obj.GetKind() != sccKind {
+			return nil
+		}
+
+		resource, err := a.resource(obj)
+		if err != nil {
+			return err
+		}
+		live, err := resource.Get(ctx, obj.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("error verifying %s %q from %s: %w", obj.GetKind(), obj.GetName(), path, err)
+		}
+
+		if fields := drift(obj.Object, live.Object); len(fields) > 0 {
+			a.log.Warn("SCC drifted from the generated manifest", "name", obj.GetName(), "file", path, "fields", fields)
+			drifted++
+			return nil
+		}
+		a.log.Info("Verified SCC", "name", obj.GetName())
+
+		return nil
+	})
+
+	return drifted, err
+}
+
+// checkDuplicates fails if a kind, namespace and name occur more than once
+// across the YAML files at paths. Applying with force would otherwise let the
+// later manifest silently take over the fields of the earlier one.
+func checkDuplicates(paths []string) error {
+	seen := map[string]string{}
+	for _, path := range paths {
+		err := forEachManifest(path, func(obj *unstructured.Unstructured) error {
+			key := fmt.Sprintf("%s %s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+			if first, ok := seen[key]; ok {
+				return fmt.Errorf("error applying %s from %s: already rendered to %s", key, path, first)
+			}
+			seen[key] = path
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// forEachManifest calls fn with every manifest in the YAML file at path, in
+// order.
+func forEachManifest(path string, fn func(*unstructured.Unstructured) error) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("error decoding %s: %w", path, err)
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+}
+
+// resource returns the client of the manifest's resource.
+func (a *applier) resource(obj *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := a.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		namespace := obj.GetNamespace()
+		if namespace == "" {
+			namespace = metav1.NamespaceDefault
+		}
+		return a.client.Resource(mapping.Resource).Namespace(namespace), nil
+	}
+
+	return a.client.Resource(mapping.Resource), nil
+}
+
+// apply server-side applies a single manifest.
+func (a *applier) apply(ctx context.Context, obj *unstructured.Unstructured) error {
+	resource, err := a.resource(obj)
+	if err != nil {
+		return err
+	}
+
+	opts := metav1.ApplyOptions{
+		FieldManager: fieldManager,
+		Force:        true,
+	}
+	if a.dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+
+	if _, err := resource.Apply(ctx, obj.GetName(), obj, opts); err != nil {
+		return err
+	}
+	a.log.Info("Applied manifest", "kind", obj.GetKind(), "namespace", obj.GetNamespace(), "name", obj.GetName(), "dryRun", a.dryRun)
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package sccgen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"wildcard.yaml":   "kind: SecurityContextConstraints\nmetadata:\n  name: my-scc-wildcard\n",
+		"unconfined.yaml": "kind: SecurityContextConstraints\nmetadata:\n  name: my-scc-unconfined\n",
+		"copy.yaml":       "kind: SecurityContextConstraints\nmetadata:\n  name: my-scc-wildcard\n",
+		"bindings.yaml": "kind: RoleBinding\nmetadata:\n  name: scc\n  namespace: a\n---\n" +
+			"kind: RoleBinding\nmetadata:\n  name: scc\n  namespace: b\n",
+		"bindings-copy.yaml": "kind: RoleBinding\nmetadata:\n  name: scc\n  namespace: a\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		name    string
+		files   []string
+		wantErr bool
+	}{
+		{name: "should accept distinct names", files: []string{"wildcard.yaml", "unconfined.yaml"}},
+		{name: "should accept the same name in different namespaces", files: []string{"bindings.yaml"}},
+		{name: "should reject a name rendered twice", files: []string{"wildcard.yaml", "unconfined.yaml", "copy.yaml"}, wantErr: true},
+		{name: "should reject a namespaced name rendered twice", files: []string{"bindings.yaml", "bindings-copy.yaml"}, wantErr: true},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var paths []string
+			for _, name := range tt.files {
+				paths = append(paths, filepath.Join(dir, name))
+			}
+
+			err := checkDuplicates(paths)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package sccgen
+
+import (
+	"fmt"
+	"sort"
+)
+
+// drift compares the fields of the generated manifest with the live object
+// and returns the paths of the fields that differ, e.g. "seccompProfiles" or
+// "metadata.labels.team". Only the fields set in the generated manifest are
+// compared, so that defaults and the metadata populated by the server, such
+// as the uid or managedFields, don't count as drift.
+func drift(generated, live map[string]interface{}) []string {
+	var fields []string
+	for _, key := range sortedKeys(generated) {
+		if key == "metadata" {
+			metadata, _ := generated[key].(map[string]interface{})
+			liveMetadata, _ := live[key].(map[string]interface{})
+			for _, field := range []string{"labels", "annotations"} {
+				if value, ok := metadata[field]; ok {
+					fields = append(fields, driftedFields("metadata."+field, value, liveMetadata[field])...)
+				}
+			}
+			continue
+		}
+
+		fields = append(fields, driftedFields(key, generated[key], live[key])...)
+	}
+
+	return fields
+}
+
+// driftedFields returns the path of every field of generated that differs in
+// live, recursing into maps and lists.
+func driftedFields(path string, generated, live interface{}) []string {
+	switch generated := generated.(type) {
+	case map[string]interface{}:
+		liveMap, ok := live.(map[string]interface{})
+		if !ok {
+			return []string{path}
+		}
+
+		var fields []string
+		for _, key := range sortedKeys(generated) {
+			fields = append(fields, driftedFields(path+"."+key, generated[key], liveMap[key])...)
+		}
+		return fields
+
+	case []interface{}:
+		liveList, ok := live.([]interface{})
+		if live == nil && len(generated) == 0 {
+			// Empty lists are left out by the server.
+			return nil
+		}
+		if !ok || len(liveList) != len(generated) {
+			return []string{path}
+		}
+
+		var fields []string
+		for i := range generated {
+			fields = append(fields, driftedFields(fmt.Sprintf("%s[%d]", path, i), generated[i], liveList[i])...)
+		}
+		return fields
+
+	default:
+		// Decoded manifests hold numbers as float64 and live objects as
+		// int64, so compare them as printed.
+		if fmt.Sprint(generated) != fmt.Sprint(live) {
+			return []string{path}
+		}
+		return nil
+	}
+}
+
+// sortedKeys returns the keys of m in order, so that drift is reported
+// deterministically.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
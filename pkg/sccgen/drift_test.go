@@ -0,0 +1,76 @@
+package sccgen
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDrift(t *testing.T) {
+	generated := map[string]interface{}{
+		"kind": "SecurityContextConstraints",
+		"metadata": map[string]interface{}{
+			"name":   "my-scc",
+			"labels": map[string]interface{}{"team": "a"},
+		},
+		"priority":                 float64(10),
+		"seccompProfiles":          []interface{}{"*"},
+		"users":                    []interface{}{"ibihim"},
+		"requiredDropCapabilities": []interface{}{},
+	}
+
+	for _, tt := range []struct {
+		name string
+		live map[string]interface{}
+		want []string
+	}{
+		{
+			name: "should ignore server-populated metadata and defaults",
+			live: map[string]interface{}{
+				"kind": "SecurityContextConstraints",
+				"metadata": map[string]interface{}{
+					"name":            "my-scc",
+					"uid":             "1234",
+					"resourceVersion": "42",
+					"labels":          map[string]interface{}{"team": "a"},
+				},
+				"priority":                 int64(10),
+				"seccompProfiles":          []interface{}{"*"},
+				"users":                    []interface{}{"ibihim"},
+				"allowHostDirVolumePlugin": false,
+			},
+		},
+		{
+			name: "should report mutated fields",
+			live: map[string]interface{}{
+				"kind": "SecurityContextConstraints",
+				"metadata": map[string]interface{}{
+					"name":   "my-scc",
+					"labels": map[string]interface{}{"team": "b"},
+				},
+				"priority":        int64(10),
+				"seccompProfiles": []interface{}{"runtime/default"},
+				"users":           []interface{}{"ibihim", "someone"},
+			},
+			want: []string{"metadata.labels.team", "seccompProfiles[0]", "users"},
+		},
+		{
+			name: "should report removed fields",
+			live: map[string]interface{}{
+				"kind":            "SecurityContextConstraints",
+				"metadata":        map[string]interface{}{"name": "my-scc"},
+				"seccompProfiles": []interface{}{"*"},
+				"users":           []interface{}{"ibihim"},
+			},
+			want: []string{"metadata.labels", "priority"},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := drift(generated, tt.live); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
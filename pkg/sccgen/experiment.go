@@ -0,0 +1,75 @@
+package sccgen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Keys of the deprecated seccomp annotations, the container annotation is
+// suffixed with the container name.
+const (
+	podSeccompAnnotation             = "seccomp.security.alpha.kubernetes.io/pod"
+	containerSeccompAnnotationPrefix = "container.seccomp.security.alpha.kubernetes.io/"
+)
+
+// PodAnnotations returns the annotations of the experiment's pod, the pod's
+// own followed by the seccomp annotations of its containers.
+func (t *DeploymentTemplate) PodAnnotations() []string {
+	annotations := append([]string{}, t.Annotations...)
+	for _, container := range t.Containers {
+		if container.SeccompAnnotation != "" {
+			annotations = append(annotations, fmt.Sprintf("%s%s: %s", containerSeccompAnnotationPrefix, container.Name, container.SeccompAnnotation))
+		}
+	}
+
+	return annotations
+}
+
+// Conflicts describes every seccomp annotation of the experiment that
+// disagrees with the seccompProfile field it was replaced by. Some
+// experiments conflict on purpose, the experiment template renders them as
+// warning comments to make that explicit.
+func (t *DeploymentTemplate) Conflicts() []string {
+	containerFields := map[string]string{}
+	for _, container := range t.Containers {
+		containerFields[container.Name] = container.SeccompField
+	}
+
+	var conflicts []string
+	for _, annotation := range t.PodAnnotations() {
+		key, value, _ := strings.Cut(annotation, ":")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		var field, fieldName string
+		switch {
+		case key == podSeccompAnnotation:
+			field, fieldName = t.PodField, "pod"
+		case strings.HasPrefix(key, containerSeccompAnnotationPrefix):
+			name := strings.TrimPrefix(key, containerSeccompAnnotationPrefix)
+			field, fieldName = containerFields[name], fmt.Sprintf("container %q", name)
+		default:
+			continue
+		}
+
+		if field != "" && seccompProfileType(value) != field {
+			conflicts = append(conflicts, fmt.Sprintf("annotation %q conflicts with the %s seccompProfile type %q", annotation, fieldName, field))
+		}
+	}
+
+	return conflicts
+}
+
+// seccompProfileType returns the seccompProfile type that replaced the value
+// of a seccomp annotation, or an empty string for unknown values.
+func seccompProfileType(annotationValue string) string {
+	switch {
+	case annotationValue == "unconfined":
+		return "Unconfined"
+	case annotationValue == "runtime/default", annotationValue == "docker/default":
+		return "RuntimeDefault"
+	case strings.HasPrefix(annotationValue, "localhost/"):
+		return "Localhost"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,87 @@
+package sccgen
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDeploymentTemplateConflicts(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		experiment *DeploymentTemplate
+		want       []string
+	}{
+		{
+			name: "should flag a pod annotation that disagrees with the pod field",
+			experiment: &DeploymentTemplate{
+				Annotations: []string{`seccomp.security.alpha.kubernetes.io/pod: unconfined`},
+				PodField:    "RuntimeDefault",
+			},
+			want: []string{`annotation "seccomp.security.alpha.kubernetes.io/pod: unconfined" conflicts with the pod seccompProfile type "RuntimeDefault"`},
+		},
+		{
+			name: "should flag a container annotation that disagrees with the container field",
+			experiment: &DeploymentTemplate{
+				Containers: busybox("RuntimeDefault", "unconfined"),
+			},
+			want: []string{`annotation "container.seccomp.security.alpha.kubernetes.io/busybox: unconfined" conflicts with the container "busybox" seccompProfile type "RuntimeDefault"`},
+		},
+		{
+			name: "should only compare a container annotation with the field of the same container",
+			experiment: &DeploymentTemplate{
+				Containers: []ContainerSpec{
+					{Name: "app", Image: "busybox", SeccompAnnotation: "unconfined"},
+					{Name: "sidecar", Image: "busybox", SeccompField: "RuntimeDefault"},
+				},
+			},
+		},
+		{
+			name: "should accept an annotation that agrees with the field",
+			experiment: &DeploymentTemplate{
+				Annotations: []string{`seccomp.security.alpha.kubernetes.io/pod: runtime/default`},
+				PodField:    "RuntimeDefault",
+			},
+		},
+		{
+			name: "should accept an annotation without a field",
+			experiment: &DeploymentTemplate{
+				Annotations: []string{`seccomp.security.alpha.kubernetes.io/pod: unconfined`},
+			},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.experiment.Conflicts(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExperimentTemplateLabelsNamespace(t *testing.T) {
+	experiment, err := parseTemplate("../../resources/scc/template/experiment.yaml")
+	if err != nil {
+		t.Fatalf("failed to parse template: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := experiment.Execute(&buf, &DeploymentTemplate{
+		Namespace:    "experiment",
+		Containers:   busybox("", ""),
+		EnforceLevel: "baseline",
+		WarnLevel:    "restricted",
+	}); err != nil {
+		t.Fatalf("failed to execute template: %v", err)
+	}
+
+	want := "kind: Namespace\nmetadata:\n  name: experiment\n  labels:\n" +
+		"    pod-security.kubernetes.io/enforce: baseline\n" +
+		"    pod-security.kubernetes.io/warn: restricted\n---\n"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("rendered experiment doesn't contain %q:\n%s", want, buf.String())
+	}
+}
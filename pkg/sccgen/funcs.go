@@ -0,0 +1,60 @@
+package sccgen
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"sigs.k8s.io/yaml"
+)
+
+// funcMap holds the helper functions available to the templates, so that
+// template data can hold raw values and leave YAML formatting to the
+// templates.
+var funcMap = template.FuncMap{
+	"quote":  quote,
+	"indent": indent,
+	"toYaml": toYaml,
+	"lower":  strings.ToLower,
+}
+
+// quote returns s as a double-quoted YAML string.
+func quote(s string) string {
+	return strconv.Quote(s)
+}
+
+// indent prefixes every non-empty line of s with spaces spaces.
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// toYaml returns v encoded as YAML, without the trailing newline.
+func toYaml(v interface{}) (string, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// parseTemplate reads the template at path and parses it with the helper
+// functions.
+func parseTemplate(path string) (*template.Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return template.New(filepath.Base(path)).Funcs(funcMap).Parse(string(data))
+}
@@ -0,0 +1,160 @@
+package sccgen
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		template string
+		data     interface{}
+		want     string
+	}{
+		{
+			name:     "should quote a wildcard profile",
+			template: `{{range .}}- {{quote .}}{{end}}`,
+			data:     []string{"*"},
+			want:     `- "*"`,
+		},
+		{
+			name:     "should indent every line",
+			template: `{{indent 2 .}}`,
+			data:     "a: b\nc: d",
+			want:     "  a: b\n  c: d",
+		},
+		{
+			name:     "should encode a value as yaml",
+			template: `{{toYaml .}}`,
+			data:     map[string][]string{"seccompProfiles": {"runtime/default"}},
+			want:     "seccompProfiles:\n- runtime/default",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpl, err := template.New(tt.name).Funcs(funcMap).Parse(tt.template)
+			if err != nil {
+				t.Fatalf("failed to parse template: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, tt.data); err != nil {
+				t.Fatalf("failed to execute template: %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSCCTemplateQuotesProfiles(t *testing.T) {
+	scc, err := parseTemplate("../../resources/scc/template/scc.yaml")
+	if err != nil {
+		t.Fatalf("failed to parse template: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := scc.Execute(&buf, &SCCTemplate{
+		Name:            "my-scc-wildcard",
+		Users:           []string{wildcardUser},
+		SeccompProfiles: []string{"*"},
+	}); err != nil {
+		t.Fatalf("failed to execute template: %v", err)
+	}
+
+	if want := "seccompProfiles:\n- \"*\"\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("rendered SCC doesn't contain %q:\n%s", want, buf.String())
+	}
+}
+
+func TestSCCTemplateRendersPriority(t *testing.T) {
+	scc, err := parseTemplate("../../resources/scc/template/scc.yaml")
+	if err != nil {
+		t.Fatalf("failed to parse template: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		priority *int
+		want     bool
+	}{
+		{name: "should render a zero priority", priority: intPtr(0), want: true},
+		{name: "should render a priority", priority: intPtr(10), want: true},
+		{name: "should leave an unset priority out", priority: nil, want: false},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			if err := scc.Execute(&buf, &SCCTemplate{
+				Name:            "my-scc-wildcard",
+				Users:           []string{wildcardUser},
+				SeccompProfiles: []string{"*"},
+				Priority:        tt.priority,
+			}); err != nil {
+				t.Fatalf("failed to execute template: %v", err)
+			}
+
+			got := strings.Contains(buf.String(), "\npriority: ")
+			if got != tt.want {
+				t.Errorf("expected priority rendered to be %v:\n%s", tt.want, buf.String())
+			}
+			if tt.want && !strings.Contains(buf.String(), fmt.Sprintf("\npriority: %d\n", *tt.priority)) {
+				t.Errorf("rendered SCC doesn't contain priority %d:\n%s", *tt.priority, buf.String())
+			}
+		})
+	}
+}
+
+func intPtr(i int) *int { return &i }
+
+func TestDefaultSCCsRenderDistinctNames(t *testing.T) {
+	scc, err := parseTemplate("../../resources/scc/template/scc.yaml")
+	if err != nil {
+		t.Fatalf("failed to parse template: %v", err)
+	}
+
+	seen := map[string]string{}
+	for _, sccData := range defaultSCCs() {
+		sccData.Namespace = "scc-test"
+		sccData.ServiceAccounts = []string{"default"}
+
+		var buf bytes.Buffer
+		if err := scc.Execute(&buf, sccData); err != nil {
+			t.Fatalf("failed to execute template: %v", err)
+		}
+		path := filepath.Join(t.TempDir(), "scc.yaml")
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatalf("failed to write SCC: %v", err)
+		}
+
+		err := forEachManifest(path, func(obj *unstructured.Unstructured) error {
+			key := obj.GetKind() + "/" + obj.GetName()
+			if user, ok := seen[key]; ok {
+				t.Errorf("SCCs of %s and %s both render %s", user, sccData.Users[0], key)
+			}
+			seen[key] = sccData.Users[0]
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("failed to read rendered SCC: %v", err)
+		}
+	}
+
+	if len(seen) != 6 {
+		t.Errorf("expected an SCC, ClusterRole and RoleBinding per SCC, got %v", seen)
+	}
+}
@@ -0,0 +1,29 @@
+package sccgen
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// readIdentities reads one user or group per line from the file. Blank lines
+// and lines starting with # are skipped.
+func readIdentities(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var identities []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		identities = append(identities, line)
+	}
+
+	return identities, scanner.Err()
+}
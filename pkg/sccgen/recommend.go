@@ -0,0 +1,178 @@
+package sccgen
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"text/template"
+)
+
+// restrictedV2 is the name of the default SCC of OpenShift that admits pods
+// meeting the restricted PodSecurity level.
+const restrictedV2 = "restricted-v2"
+
+// Recommendation is a candidate SCC that admits the pods of a single
+// workload, starting from restricted-v2 and relaxing only the fields that the
+// workload's violations require.
+type Recommendation struct {
+	Name           string
+	Namespace      string
+	ServiceAccount string
+	// Kind and Cluster are optional, they tell apart workloads of different
+	// kinds or clusters that share a name.
+	Kind    string
+	Cluster string
+
+	AllowPrivilegedContainer bool
+	AllowPrivilegeEscalation bool
+	AllowedCapabilities      []string
+	RequiredDropCapabilities []string
+	RunAsUser                string
+	SeccompProfiles          []string
+
+	// Relaxed lists the controls that made the recommendation deviate from
+	// restricted-v2.
+	Relaxed []string
+	// Unmapped lists the controls that aren't mapped to SCC fields.
+	Unmapped []string
+}
+
+// Recommend maps the violated PodSecurity controls of a workload to the SCC
+// fields that must be relaxed for it. Capabilities lists the capabilities
+// the workload adds beyond the default set.
+func Recommend(name, namespace, serviceAccount string, controls, capabilities []string) *Recommendation {
+	r := &Recommendation{
+		Name:                     name,
+		Namespace:                namespace,
+		ServiceAccount:           serviceAccount,
+		AllowedCapabilities:      []string{"NET_BIND_SERVICE"},
+		RequiredDropCapabilities: []string{"ALL"},
+		RunAsUser:                "MustRunAsRange",
+		SeccompProfiles:          []string{"runtime/default"},
+	}
+
+	for _, control := range controls {
+		switch control {
+		case "privileged":
+			r.AllowPrivilegedContainer = true
+		case "allowPrivilegeEscalation != false":
+			r.AllowPrivilegeEscalation = true
+		case "unrestricted capabilities":
+			r.RequiredDropCapabilities = nil
+		case "non-default capabilities":
+			r.AllowedCapabilities = mergeSorted(r.AllowedCapabilities, capabilities)
+		case "runAsNonRoot != true", "runAsUser=0":
+			r.RunAsUser = "RunAsAny"
+		case "seccompProfile":
+			r.SeccompProfiles = []string{"*"}
+		default:
+			r.Unmapped = AppendUnique(r.Unmapped, control)
+			continue
+		}
+		r.Relaxed = AppendUnique(r.Relaxed, control)
+	}
+
+	return r
+}
+
+// RestrictedV2Suffices reports whether restricted-v2 already admits the
+// workload, so that no custom SCC is needed.
+func (r *Recommendation) RestrictedV2Suffices() bool {
+	return len(r.Relaxed) == 0 && len(r.Unmapped) == 0
+}
+
+// User returns the SCC user of the workload's service account.
+func (r *Recommendation) User() string {
+	return fmt.Sprintf("system:serviceaccount:%s:%s", r.Namespace, r.ServiceAccount)
+}
+
+// recommendationTemplate renders a Recommendation, or a note that
+// restricted-v2 suffices.
+var recommendationTemplate = template.Must(template.New("recommendation").Funcs(funcMap).Parse(`
+{{- define "workload"}}{{with .Cluster}}{{.}}/{{end}}{{.Namespace}}/{{with .Kind}}{{.}}/{{end}}{{.Name}}{{end}}
+{{- if .RestrictedV2Suffices -}}
+# {{template "workload" .}}: ` + restrictedV2 + ` suffices, no custom SCC needed.
+{{- else -}}
+# {{template "workload" .}}: relaxes ` + restrictedV2 + ` for{{range .Relaxed}} "{{.}}"{{end}}
+{{- if .Unmapped}}
+# Review manually, not mapped to SCC fields:{{range .Unmapped}} "{{.}}"{{end}}
+{{- end}}
+kind: SecurityContextConstraints
+apiVersion: security.openshift.io/v1
+metadata:
+  name: {{.Namespace}}-{{with .Kind}}{{lower .}}-{{end}}{{.Name}}
+allowPrivilegedContainer: {{.AllowPrivilegedContainer}}
+allowPrivilegeEscalation: {{.AllowPrivilegeEscalation}}
+allowHostDirVolumePlugin: false
+allowHostIPC: false
+allowHostNetwork: false
+allowHostPID: false
+allowHostPorts: false
+readOnlyRootFilesystem: false
+allowedCapabilities:
+{{- range .AllowedCapabilities}}
+- {{.}}
+{{- end}}
+requiredDropCapabilities:
+{{- range .RequiredDropCapabilities}}
+- {{.}}
+{{- else}} []
+{{- end}}
+runAsUser:
+  type: {{.RunAsUser}}
+seLinuxContext:
+  type: MustRunAs
+fsGroup:
+  type: MustRunAs
+supplementalGroups:
+  type: RunAsAny
+seccompProfiles:
+{{- range .SeccompProfiles}}
+- {{printf "%q" .}}
+{{- end}}
+volumes:
+- configMap
+- csi
+- downwardAPI
+- emptyDir
+- ephemeral
+- persistentVolumeClaim
+- projected
+- secret
+users:
+- {{.User}}
+{{- end}}
+`))
+
+// YAML renders the recommendation as an SCC manifest.
+func (r *Recommendation) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := recommendationTemplate.Execute(&buf, r); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// mergeSorted returns the sorted union of a and b.
+func mergeSorted(a, b []string) []string {
+	merged := append([]string{}, a...)
+	for _, s := range b {
+		merged = AppendUnique(merged, s)
+	}
+	sort.Strings(merged)
+
+	return merged
+}
+
+// AppendUnique appends s to list unless it is already in it, keeping the
+// order of list.
+func AppendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+
+	return append(list, s)
+}
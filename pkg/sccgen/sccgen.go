@@ -0,0 +1,486 @@
+package sccgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/ibihim/kube-plays/pkg/cmdutil"
+)
+
+const (
+	defaultExperimentPath = "./template/experiment.yaml"
+	defaultSCCPath        = "./template/scc.yaml"
+	defaultOutPath        = "./out"
+
+	wildcardUser   = "ibihim"
+	unconfinedUser = "kostrows"
+)
+
+type SCCTemplate struct {
+	// Name is the name of the SCC, its ClusterRole and RoleBinding are
+	// named after it.
+	Name            string
+	Users           []string
+	Groups          []string
+	SeccompProfiles []string
+
+	// Namespace and ServiceAccounts are optional. If both are set, a
+	// ClusterRole granting use of the SCC and a RoleBinding of it to the
+	// service accounts in the namespace are rendered along with the SCC.
+	Namespace       string
+	ServiceAccounts []string
+
+	// Priority is optional, SCCs with a higher priority are preferred over
+	// others, such as restricted-v2, that admit the pod as well.
+	Priority *int
+}
+
+// validate fails if the SCC has no name, would admit nobody or no seccomp
+// profile, or if its priority is negative.
+func (t *SCCTemplate) validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("SCC of users %q has no name", t.Users)
+	}
+	if len(t.Users) == 0 {
+		return fmt.Errorf("SCC with seccomp profiles %q has no users", t.SeccompProfiles)
+	}
+	if len(t.SeccompProfiles) == 0 {
+		return fmt.Errorf("SCC of users %q has no seccomp profiles", t.Users)
+	}
+	if t.Priority != nil && *t.Priority < 0 {
+		return fmt.Errorf("SCC of users %q has negative priority %d", t.Users, *t.Priority)
+	}
+
+	return nil
+}
+
+type DeploymentTemplate struct {
+	Namespace string
+	// Annotations are set on the pod as "key: value" strings.
+	Annotations []string
+	PodField    string
+	Containers  []ContainerSpec
+
+	// EnforceLevel, WarnLevel and AuditLevel are optional PodSecurity levels
+	// set as labels of the namespace rendered ahead of the workload.
+	EnforceLevel string
+	WarnLevel    string
+	AuditLevel   string
+}
+
+// ContainerSpec is a container of an experiment's pod.
+type ContainerSpec struct {
+	Name  string
+	Image string
+	// SeccompField is the type of the container's seccompProfile field.
+	SeccompField string
+	// SeccompAnnotation is the value of the container's deprecated seccomp
+	// annotation, e.g. "unconfined".
+	SeccompAnnotation string
+}
+
+// busybox returns the single busybox container of most experiments, with the
+// given seccompProfile type and seccomp annotation, either may be empty.
+func busybox(seccompField, seccompAnnotation string) []ContainerSpec {
+	return []ContainerSpec{{
+		Name:              "busybox",
+		Image:             "busybox",
+		SeccompField:      seccompField,
+		SeccompAnnotation: seccompAnnotation,
+	}}
+}
+
+// validate fails if the experiment has no namespace to render into, no
+// containers, or an unknown PodSecurity level.
+func (t *DeploymentTemplate) validate() error {
+	if t.Namespace == "" {
+		return fmt.Errorf("experiment with pod field %q and annotations %q has no namespace", t.PodField, t.Annotations)
+	}
+
+	if len(t.Containers) == 0 {
+		return fmt.Errorf("experiment %s has no containers", t.Namespace)
+	}
+	for i, container := range t.Containers {
+		if container.Name == "" || container.Image == "" {
+			return fmt.Errorf("container %d of experiment %s needs a name and an image", i, t.Namespace)
+		}
+	}
+
+	for _, level := range []string{t.EnforceLevel, t.WarnLevel, t.AuditLevel} {
+		switch level {
+		case "", "privileged", "baseline", "restricted":
+		default:
+			return fmt.Errorf("experiment %s has unknown PodSecurity level %q, must be privileged, baseline or restricted", t.Namespace, level)
+		}
+	}
+
+	return nil
+}
+
+// renderedManifest describes a file written by gen-scc, as listed by
+// --manifest-list.
+type renderedManifest struct {
+	Path string `json:"path"`
+	// Kinds lists the kinds of the objects in the file, in order.
+	Kinds     []string `json:"kinds"`
+	Namespace string   `json:"namespace,omitempty"`
+	Users     []string `json:"users,omitempty"`
+}
+
+type options struct {
+	sccPath        string
+	experimentPath string
+	outPath        string
+	clean          bool
+	kustomization  bool
+	apply          bool
+	dryRun         string
+	manifestList   bool
+	usersFile      string
+	groupsFile     string
+	verify         bool
+}
+
+// NewCommand returns the gen-scc command, which renders the SCC and
+// experiment templates. Its default paths expect it to run from resources/scc.
+func NewCommand(flags *cmdutil.Flags) *cobra.Command {
+	opts := &options{}
+
+	cmd := &cobra.Command{
+		Use:   "gen-scc",
+		Short: "Render the SCC and seccomp experiment manifests",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd.Context(), flags, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.sccPath, "scc-template", defaultSCCPath, "path of the SCC template")
+	cmd.Flags().StringVar(&opts.experimentPath, "experiment-template", defaultExperimentPath, "path of the experiment template")
+	cmd.Flags().StringVar(&opts.outPath, "out-dir", defaultOutPath, "directory to write the rendered manifests to")
+	cmd.Flags().BoolVar(&opts.clean, "clean", false, "remove the output directory before rendering")
+	cmd.Flags().BoolVar(&opts.kustomization, "kustomization", false, "also write a kustomization.yaml listing the rendered manifests to the output directory")
+	cmd.Flags().BoolVar(&opts.apply, "apply", false, "server-side apply the rendered manifests to the cluster")
+	cmd.Flags().StringVar(&opts.usersFile, "users-file", "", "file with one user per line to add to every SCC; blank lines and # comments are skipped")
+	cmd.Flags().StringVar(&opts.groupsFile, "groups-file", "", "file with one group per line to add to every SCC; blank lines and # comments are skipped")
+	cmd.Flags().BoolVar(&opts.manifestList, "manifest-list", false, "print a JSON list of the written files with their kinds, namespace and users to stdout")
+	cmd.Flags().BoolVar(&opts.verify, "verify", false, "with --apply, re-read the applied SCCs and fail if they drifted from the rendered manifests")
+	cmd.Flags().StringVar(&opts.dryRun, "dry-run", dryRunNone, "with --apply, \"server\" only validates the manifests against the cluster")
+
+	return cmd
+}
+
+func run(ctx context.Context, flags *cmdutil.Flags, opts *options) error {
+	if opts.dryRun != dryRunNone && opts.dryRun != dryRunServer {
+		return fmt.Errorf("unknown --dry-run mode %q, must be %q or %q", opts.dryRun, dryRunNone, dryRunServer)
+	}
+	if opts.dryRun == dryRunServer && !opts.apply {
+		return fmt.Errorf("--dry-run=%s requires --apply", dryRunServer)
+	}
+	if opts.verify && (!opts.apply || opts.dryRun == dryRunServer) {
+		return fmt.Errorf("--verify requires --apply without --dry-run=%s", dryRunServer)
+	}
+
+	var rendered []renderedManifest
+
+	sccUsers := defaultSCCs()
+
+	if err := addIdentities(sccUsers, opts.usersFile, opts.groupsFile); err != nil {
+		return err
+	}
+
+	experiments := []*DeploymentTemplate{
+		{
+			Namespace:  "wildcard-pod-no-annotations-no-fields",
+			Containers: busybox("", ""),
+		},
+		{
+			Namespace:  "unconfined-pod-no-annotations-no-fields",
+			Containers: busybox("", ""),
+		},
+		{
+			Namespace:   "wildcard-pod-annotations-no-fields",
+			Annotations: []string{`seccomp.security.alpha.kubernetes.io/pod: unconfined`},
+			Containers:  busybox("", ""),
+		},
+		{
+			Namespace:   "unconfined-pod-annotations-no-fields",
+			Annotations: []string{`seccomp.security.alpha.kubernetes.io/pod: unconfined`},
+			Containers:  busybox("", ""),
+		},
+		{
+			Namespace:  "wildcard-pod-no-annotations-fields",
+			PodField:   "Unconfined",
+			Containers: busybox("", ""),
+		},
+		{
+			Namespace:  "unconfined-pod-no-annotations-fields",
+			PodField:   "Unconfined",
+			Containers: busybox("", ""),
+		},
+		{
+			Namespace:  "wildcard-container-annotations-no-fields",
+			Containers: busybox("", "unconfined"),
+		},
+		{
+			Namespace:  "unconfined-container-annotations-no-fields",
+			Containers: busybox("", "unconfined"),
+		},
+		{
+			Namespace:  "wildcard-container-no-annotations-fields",
+			Containers: busybox("Unconfined", ""),
+		},
+		{
+			Namespace:  "unconfined-container-no-annotations-fields",
+			Containers: busybox("Unconfined", ""),
+		},
+		{
+			Namespace:   "unconfined-pod-annotations-fields-conflict",
+			Annotations: []string{`seccomp.security.alpha.kubernetes.io/pod: unconfined`},
+			PodField:    "RuntimeDefault",
+			Containers:  busybox("", ""),
+		},
+		{
+			Namespace:  "unconfined-container-annotations-fields-conflict",
+			Containers: busybox("RuntimeDefault", "unconfined"),
+		},
+	}
+
+	// Validate all templates before rendering any, so that bad data doesn't
+	// leave a partially rendered output directory behind.
+	for _, sccData := range sccUsers {
+		if err := sccData.validate(); err != nil {
+			return err
+		}
+	}
+	for _, experimentData := range experiments {
+		if err := experimentData.validate(); err != nil {
+			return err
+		}
+	}
+
+	if opts.clean {
+		if err := os.RemoveAll(opts.outPath); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(opts.outPath, 0755); err != nil {
+		return err
+	}
+
+	for _, sccData := range sccUsers {
+		var yamlBuilder bytes.Buffer
+
+		scc, err := parseTemplate(opts.sccPath)
+		if err != nil {
+			return err
+		}
+		if err := scc.Execute(&yamlBuilder, sccData); err != nil {
+			return fmt.Errorf("error rendering SCC of users %q: %w", sccData.Users, err)
+		}
+
+		outputPath := filepath.Join(opts.outPath, fmt.Sprintf("scc-%s.yaml", sccData.Users[0]))
+		if err := ioutil.WriteFile(outputPath, yamlBuilder.Bytes(), 0644); err != nil {
+			return err
+		}
+		kinds, err := manifestKinds(outputPath)
+		if err != nil {
+			return err
+		}
+		rendered = append(rendered, renderedManifest{
+			Path:      outputPath,
+			Kinds:     kinds,
+			Namespace: sccData.Namespace,
+			Users:     sccData.Users,
+		})
+	}
+
+	for _, experimentData := range experiments {
+		var yamlBuilder bytes.Buffer
+
+		experiment, err := parseTemplate(opts.experimentPath)
+		if err != nil {
+			return err
+		}
+		if err := experiment.Execute(&yamlBuilder, experimentData); err != nil {
+			return fmt.Errorf("error rendering experiment %s: %w", experimentData.Namespace, err)
+		}
+
+		outputPath := filepath.Join(opts.outPath, fmt.Sprintf("%s.yaml", experimentData.Namespace))
+		if err := ioutil.WriteFile(outputPath, yamlBuilder.Bytes(), 0644); err != nil {
+			return err
+		}
+		kinds, err := manifestKinds(outputPath)
+		if err != nil {
+			return err
+		}
+		rendered = append(rendered, renderedManifest{
+			Path:      outputPath,
+			Kinds:     kinds,
+			Namespace: experimentData.Namespace,
+		})
+	}
+
+	written := rendered
+	if opts.kustomization {
+		path, err := writeKustomization(opts.outPath, rendered)
+		if err != nil {
+			return err
+		}
+		written = append(written, renderedManifest{Path: path, Kinds: []string{"Kustomization"}})
+	}
+
+	if opts.manifestList {
+		if err := writeManifestList(os.Stdout, written); err != nil {
+			return err
+		}
+	}
+
+	if !opts.apply {
+		return nil
+	}
+
+	paths := make([]string, 0, len(rendered))
+	for _, manifest := range rendered {
+		paths = append(paths, manifest.Path)
+	}
+	if err := checkDuplicates(paths); err != nil {
+		return err
+	}
+
+	a, err := newApplier(flags, opts.dryRun == dryRunServer)
+	if err != nil {
+		return err
+	}
+	for _, manifest := range rendered {
+		if err := a.applyFile(ctx, manifest.Path); err != nil {
+			return err
+		}
+	}
+
+	if !opts.verify {
+		return nil
+	}
+
+	var drifted int
+	for _, manifest := range rendered {
+		n, err := a.verifyFile(ctx, manifest.Path)
+		if err != nil {
+			return err
+		}
+		drifted += n
+	}
+	if drifted > 0 {
+		return fmt.Errorf("%d SCCs drifted from the rendered manifests", drifted)
+	}
+
+	return nil
+}
+
+// defaultSCCs returns the SCCs gen-scc renders: one admitting any seccomp
+// profile and one admitting only Unconfined.
+func defaultSCCs() []*SCCTemplate {
+	return []*SCCTemplate{
+		{
+			Name:            "my-scc-wildcard",
+			Users:           []string{wildcardUser},
+			SeccompProfiles: []string{"*"},
+		},
+		{
+			Name:            "my-scc-unconfined",
+			Users:           []string{unconfinedUser},
+			SeccompProfiles: []string{"Unconfined"},
+		},
+	}
+}
+
+// addIdentities adds the users and groups listed in the files, if any, to
+// every SCC.
+func addIdentities(sccs []*SCCTemplate, usersFile, groupsFile string) error {
+	var users, groups []string
+	if usersFile != "" {
+		var err error
+		if users, err = readIdentities(usersFile); err != nil {
+			return fmt.Errorf("error reading users file: %w", err)
+		}
+	}
+	if groupsFile != "" {
+		var err error
+		if groups, err = readIdentities(groupsFile); err != nil {
+			return fmt.Errorf("error reading groups file: %w", err)
+		}
+	}
+
+	for _, scc := range sccs {
+		for _, user := range users {
+			scc.Users = AppendUnique(scc.Users, user)
+		}
+		for _, group := range groups {
+			scc.Groups = AppendUnique(scc.Groups, group)
+		}
+	}
+
+	return nil
+}
+
+// manifestKinds returns the distinct kinds of the manifests in the YAML file
+// at path, in the order they first occur.
+func manifestKinds(path string) ([]string, error) {
+	var kinds []string
+	err := forEachManifest(path, func(obj *unstructured.Unstructured) error {
+		kinds = AppendUnique(kinds, obj.GetKind())
+		return nil
+	})
+
+	return kinds, err
+}
+
+// writeManifestList writes the manifests as a JSON list to out, so that
+// scripts know what was written without globbing the output directory.
+func writeManifestList(out io.Writer, manifests []renderedManifest) error {
+	data, err := json.MarshalIndent(manifests, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	_, err = out.Write(data)
+	return err
+}
+
+// writeKustomization writes a kustomization.yaml to outPath that lists the
+// rendered manifests, sorted, so that they can be applied with
+// "kubectl apply -k". It returns the path of the kustomization.yaml.
+func writeKustomization(outPath string, rendered []renderedManifest) (string, error) {
+	resources := make([]string, 0, len(rendered))
+	for _, manifest := range rendered {
+		resources = append(resources, filepath.Base(manifest.Path))
+	}
+	sort.Strings(resources)
+
+	var kustomization bytes.Buffer
+	kustomization.WriteString("apiVersion: kustomize.config.k8s.io/v1beta1\n")
+	kustomization.WriteString("kind: Kustomization\n")
+	kustomization.WriteString("resources:\n")
+	for _, resource := range resources {
+		fmt.Fprintf(&kustomization, "- %s\n", resource)
+	}
+
+	path := filepath.Join(outPath, "kustomization.yaml")
+	if err := ioutil.WriteFile(path, kustomization.Bytes(), 0644); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}